@@ -0,0 +1,299 @@
+package arc
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+// ARC(Adaptive Replacement Cache)
+// t1保存只访问过一次的结点,t2保存访问过多次的结点
+// b1,b2分别为从t1,t2淘汰的幽灵结点,只记录key和大小,用于自适应调整t1的目标容量p
+// 按字节计算容量,maxBytes为0时不淘汰
+type Cache struct {
+	maxBytes  int64
+	p         int64 //t1的目标字节数
+	t1, t2    *segment
+	b1, b2    *segment
+	cache     map[string]*list.Element
+	OnEvicted eviction.EvictedFunc
+}
+
+// 构造函数
+func New(maxBytes int64, OnEvicted eviction.EvictedFunc) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		t1:        newSegment(),
+		t2:        newSegment(),
+		b1:        newSegment(),
+		b2:        newSegment(),
+		cache:     make(map[string]*list.Element),
+		OnEvicted: OnEvicted,
+	}
+}
+
+// LRU链表,记录所含结点的总字节数
+type segment struct {
+	lst   *list.List
+	bytes int64
+}
+
+func newSegment() *segment {
+	return &segment{lst: list.New()}
+}
+
+// 链表存储的结点类型,幽灵结点的value为nil
+type entry struct {
+	key    string
+	value  eviction.Value
+	size   int64
+	expire time.Time //过期时间,零值表示永不过期
+	owner  *segment
+}
+
+// 将结点移动到seg的MRU端
+func (c *Cache) moveTo(ele *list.Element, seg *segment) *list.Element {
+	kv := ele.Value.(*entry)
+	kv.owner.lst.Remove(ele)
+	kv.owner.bytes -= kv.size
+	kv.owner = seg
+	seg.bytes += kv.size
+	ele = seg.lst.PushBack(kv)
+	c.cache[kv.key] = ele
+	return ele
+}
+
+// 从缓存中查询key,命中的结点移入t2,已过期的key视为未命中并被惰性删除
+func (c *Cache) Get(key string) (value eviction.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if kv.owner != c.t1 && kv.owner != c.t2 {
+		return nil, false
+	}
+	if eviction.IsExpired(kv.expire, time.Now()) {
+		c.removeElement(ele, eviction.Expired)
+		return nil, false
+	}
+	c.moveTo(ele, c.t2)
+	return kv.value, true
+}
+
+// 添加或修改缓存键值对,永不过期
+func (c *Cache) Add(key string, value eviction.Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加或修改缓存键值对,ttl<=0表示永不过期
+func (c *Cache) AddWithTTL(key string, value eviction.Value, ttl time.Duration) {
+	expire := eviction.Deadline(ttl)
+	size := int64(len(key)) + int64(value.Len())
+	ele, ok := c.cache[key]
+	if !ok {
+		kv := &entry{key: key, value: value, size: size, expire: expire, owner: c.t1}
+		c.t1.bytes += size
+		c.cache[key] = c.t1.lst.PushBack(kv)
+		c.replace(false)
+		c.trimGhosts()
+		return
+	}
+	kv := ele.Value.(*entry)
+	switch kv.owner {
+	case c.b1:
+		//t1的幽灵被再次访问,说明t1过小
+		c.p = min(c.maxBytes, c.p+max(ratio(c.b2.bytes, c.b1.bytes), 1)*size)
+	case c.b2:
+		//t2的幽灵被再次访问,说明t2过小
+		c.p = max(0, c.p-max(ratio(c.b1.bytes, c.b2.bytes), 1)*size)
+	}
+	inB2 := kv.owner == c.b2
+	kv.owner.bytes += size - kv.size
+	kv.value, kv.size, kv.expire = value, size, expire
+	c.moveTo(ele, c.t2)
+	c.replace(inB2)
+	c.trimGhosts()
+}
+
+// 整数除法,除数为0时返回0
+func ratio(a, b int64) int64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// 淘汰t1或t2中的结点直到容量满足要求
+func (c *Cache) replace(inB2 bool) {
+	for c.maxBytes != 0 && c.t1.bytes+c.t2.bytes > c.maxBytes {
+		if c.t1.lst.Len() > 0 && (c.t1.bytes > c.p || (inB2 && c.t1.bytes == c.p) || c.t2.lst.Len() == 0) {
+			c.evict(c.t1, c.b1)
+		} else {
+			c.evict(c.t2, c.b2)
+		}
+	}
+}
+
+// 将seg的LRU结点淘汰为ghost中的幽灵结点
+func (c *Cache) evict(seg, ghost *segment) {
+	ele := seg.lst.Front()
+	kv := ele.Value.(*entry)
+	value := kv.value
+	kv.value = nil
+	c.moveTo(ele, ghost)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, value, eviction.Capacity)
+	}
+}
+
+// 限制幽灵结点的规模: t1+b1不超过maxBytes,总量不超过2*maxBytes
+func (c *Cache) trimGhosts() {
+	if c.maxBytes == 0 {
+		return
+	}
+	for c.b1.lst.Len() > 0 && c.t1.bytes+c.b1.bytes > c.maxBytes {
+		c.dropGhost(c.b1)
+	}
+	for c.b2.lst.Len() > 0 && c.t1.bytes+c.t2.bytes+c.b1.bytes+c.b2.bytes > 2*c.maxBytes {
+		c.dropGhost(c.b2)
+	}
+}
+
+// 丢弃幽灵链表的LRU结点
+func (c *Cache) dropGhost(ghost *segment) {
+	ele := ghost.lst.Front()
+	kv := ele.Value.(*entry)
+	ghost.lst.Remove(ele)
+	ghost.bytes -= kv.size
+	delete(c.cache, kv.key)
+}
+
+// 删除缓存结点并触发回调
+func (c *Cache) removeElement(ele *list.Element, reason eviction.Reason) {
+	kv := ele.Value.(*entry)
+	kv.owner.lst.Remove(ele)
+	kv.owner.bytes -= kv.size
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+// 缓存淘汰
+func (c *Cache) RemoveOldest() {
+	if c.t1.lst.Len() > 0 && (c.t1.bytes > c.p || c.t2.lst.Len() == 0) {
+		c.evict(c.t1, c.b1)
+	} else if c.t2.lst.Len() > 0 {
+		c.evict(c.t2, c.b2)
+	}
+}
+
+// 清理所有已过期的结点,返回清理数量
+func (c *Cache) RemoveExpired() int {
+	now, cnt := time.Now(), 0
+	for _, seg := range []*segment{c.t1, c.t2} {
+		for ele := seg.lst.Front(); ele != nil; {
+			next := ele.Next()
+			if eviction.IsExpired(ele.Value.(*entry).expire, now) {
+				c.removeElement(ele, eviction.Expired)
+				cnt++
+			}
+			ele = next
+		}
+	}
+	return cnt
+}
+
+// 查询key但不改变其访问顺序,已过期的key视为未命中
+func (c *Cache) Peek(key string) (value eviction.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if (kv.owner != c.t1 && kv.owner != c.t2) || eviction.IsExpired(kv.expire, time.Now()) {
+			return nil, false
+		}
+		return kv.value, true
+	}
+	return nil, false
+}
+
+// 判断key是否在缓存中,不改变其访问顺序
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// 删除key,返回key是否存在,幽灵结点会被直接丢弃
+func (c *Cache) Remove(key string) bool {
+	ele, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ele.Value.(*entry)
+	if kv.value == nil {
+		kv.owner.lst.Remove(ele)
+		kv.owner.bytes -= kv.size
+		delete(c.cache, key)
+		return false
+	}
+	c.removeElement(ele, eviction.Removed)
+	return true
+}
+
+// 按淘汰顺序返回所有未过期的key
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.Len())
+	c.Range(func(key string, value eviction.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// 按淘汰顺序遍历未过期的结点,fn返回false时停止
+func (c *Cache) Range(fn func(key string, value eviction.Value) bool) {
+	now := time.Now()
+	for _, seg := range []*segment{c.t1, c.t2} {
+		for ele := seg.lst.Front(); ele != nil; ele = ele.Next() {
+			kv := ele.Value.(*entry)
+			if eviction.IsExpired(kv.expire, now) {
+				continue
+			}
+			if !fn(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// 调整缓存容量,返回因此被淘汰的结点数
+func (c *Cache) Resize(maxBytes int64) int {
+	before := c.Len()
+	c.maxBytes = maxBytes
+	c.p = min(c.p, maxBytes)
+	c.replace(false)
+	c.trimGhosts()
+	return before - c.Len()
+}
+
+// 清空缓存与幽灵结点,对每个缓存结点触发回调
+func (c *Cache) Purge() {
+	for _, seg := range []*segment{c.t1, c.t2} {
+		for seg.lst.Len() > 0 {
+			c.removeElement(seg.lst.Front(), eviction.Removed)
+		}
+	}
+	clear(c.cache)
+	c.b1, c.b2, c.p = newSegment(), newSegment(), 0
+}
+
+// 缓存中的结点数,不含幽灵结点
+func (c *Cache) Len() int {
+	return c.t1.lst.Len() + c.t2.lst.Len()
+}
+
+// 缓存已使用的字节数,不含幽灵列表
+func (c *Cache) Bytes() int64 {
+	return c.t1.bytes + c.t2.bytes
+}
@@ -0,0 +1,139 @@
+package arc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+func TestGet(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	if v, ok := arc.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := arc.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+func TestRemoveoldest(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "k3"
+	v1, v2, v3 := "value1", "value2", "v3"
+	cap := len(k1 + k2 + v1 + v2)
+	arc := New(int64(cap), nil)
+	arc.Add(k1, String(v1))
+	arc.Add(k2, String(v2))
+	arc.Add(k3, String(v3))
+
+	if _, ok := arc.Get("key1"); ok || arc.Len() != 2 {
+		t.Fatalf("Removeoldest key1 failed")
+	}
+}
+func TestOnEvicted(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value eviction.Value, reason eviction.Reason) {
+		keys = append(keys, key)
+	}
+	arc := New(int64(10), callback)
+	arc.Add("key1", String("123456"))
+	arc.Add("k2", String("k2"))
+	arc.Add("k3", String("k3"))
+	arc.Add("k4", String("k4"))
+
+	expect := []string{"key1", "k2"}
+
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
+	}
+}
+func TestScanResistance(t *testing.T) {
+	arc := New(int64(40), nil)
+	hot := []string{"h1", "h2", "h3"}
+	for _, key := range hot {
+		arc.Add(key, String("vv"))
+		arc.Get(key)
+	}
+	//一次性扫描大量冷数据
+	for i := range 100 {
+		arc.Add(fmt.Sprintf("c%02d", i), String("v"))
+	}
+	for _, key := range hot {
+		if _, ok := arc.Get(key); !ok {
+			t.Fatalf("hot key %s flushed by scan", key)
+		}
+	}
+}
+func TestGhostHit(t *testing.T) {
+	arc := New(int64(12), nil)
+	arc.Add("k1", String("v1"))
+	arc.Add("k2", String("v2"))
+	arc.Get("k2")
+	arc.Add("k3", String("v3"))
+	arc.Add("k4", String("v4"))
+	if _, ok := arc.Get("k1"); ok {
+		t.Fatalf("k1 should be evicted")
+	}
+	//k1仍在幽灵链表b1中,再次写入会增大t1的目标容量并直接进入t2
+	arc.Add("k1", String("v1"))
+	if arc.p == 0 {
+		t.Fatalf("ghost hit in b1 should increase p")
+	}
+	if _, ok := arc.Get("k1"); !ok || arc.Len() != 3 {
+		t.Fatalf("readd k1 failed")
+	}
+}
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]eviction.Reason)
+	arc := New(int64(0), func(key string, value eviction.Value, reason eviction.Reason) {
+		reasons[key] = reason
+	})
+	arc.AddWithTTL("key1", String("1234"), 10*time.Millisecond)
+	arc.AddWithTTL("key2", String("5678"), 10*time.Millisecond)
+	arc.Add("key3", String("9"))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := arc.Get("key1"); ok || reasons["key1"] != eviction.Expired {
+		t.Fatalf("lazy expiration of key1 failed")
+	}
+	if n := arc.RemoveExpired(); n != 1 || arc.Len() != 1 {
+		t.Fatalf("RemoveExpired should remove 1 key, but %d removed", n)
+	}
+}
+func TestRemove(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	arc.Add("key2", String("5678"))
+	if !arc.Remove("key1") || arc.Contains("key1") || arc.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if arc.Remove("key3") {
+		t.Fatalf("Remove nonexistent key3 should return false")
+	}
+	if v, ok := arc.Peek("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("Peek key2 failed")
+	}
+	if keys := arc.Keys(); !reflect.DeepEqual(keys, []string{"key2"}) {
+		t.Fatalf("Keys should be [key2], got %v", keys)
+	}
+}
+func TestResize(t *testing.T) {
+	arc := New(int64(0), nil)
+	for i := range 10 {
+		arc.Add("k"+strconv.Itoa(i), String("v"))
+	}
+	if n := arc.Resize(15); n == 0 || arc.Len() > 5 {
+		t.Fatalf("Resize should evict keys, %d evicted", n)
+	}
+	arc.Purge()
+	if arc.Len() != 0 || len(arc.Keys()) != 0 {
+		t.Fatalf("Purge failed")
+	}
+}
@@ -1,11 +1,17 @@
 package cache
 
-import "slices"
+import (
+	"slices"
+	"time"
+)
 
 // 只读数据结构,表示缓存值
 type ByteView struct {
 	//存储真实缓存值
-	b []byte
+	b      []byte
+	expire time.Time     //软过期时间,零值表示不会软过期
+	delta  time.Duration //从数据源加载的耗时,用于提前刷新
+	stale  bool          //是否为已过期的旧值
 }
 
 // 实现Value接口
@@ -13,6 +19,11 @@ func (View ByteView) Len() int {
 	return len(View.b)
 }
 
+// 是否为已过期的旧值: 后台刷新期间返回的软过期值,或加载失败时从宽限区返回的值
+func (View ByteView) Stale() bool {
+	return View.stale
+}
+
 // ByteView是只读的,使用该方法返回一个拷贝,防止缓存值被外部程序修改
 func (View ByteView) ByteSlice() []byte {
 	return CloneBytes(View.b)
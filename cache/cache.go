@@ -3,33 +3,287 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/LudensCS/Cache/cache/arc"
+	"github.com/LudensCS/Cache/cache/eviction"
+	"github.com/LudensCS/Cache/cache/lfu"
 	"github.com/LudensCS/Cache/cache/lru"
+	"github.com/LudensCS/Cache/cache/tinylfu"
+	"github.com/LudensCS/Cache/cache/twoq"
 )
 
-// 多线程安全缓存
+// 缓存淘汰策略
+type EvictionPolicy int
+
+const (
+	LRU      EvictionPolicy = iota //最近最少使用
+	LFU                            //最不经常使用
+	ARC                            //自适应替换
+	TwoQueue                       //2Q
+	TinyLFU                        //W-TinyLFU,窗口LRU+频率准入的LRU主缓存
+)
+
+// 根据淘汰策略创建对应的缓存实现
+func newPolicy(p EvictionPolicy, maxBytes int64, OnEvicted eviction.EvictedFunc) eviction.Policy {
+	switch p {
+	case LFU:
+		return lfu.New(maxBytes, OnEvicted)
+	case ARC:
+		return arc.New(maxBytes, OnEvicted)
+	case TwoQueue:
+		return twoq.New(maxBytes, OnEvicted)
+	case TinyLFU:
+		return tinylfu.New(maxBytes, OnEvicted)
+	}
+	return lru.New(maxBytes, OnEvicted)
+}
+
+// 多线程安全缓存,按key的哈希值分为多个独立加锁的分片以降低锁竞争
 type cache struct {
-	mutex      sync.Mutex
-	lru        *lru.Cache
-	CacheBytes int64
+	once       sync.Once
+	shards     []*shard
+	CacheBytes int64 //总容量,平均分配给各分片
+	Shards     int   //分片数,<=0时视为1
+	Policy     EvictionPolicy
+	Interval   time.Duration //后台清理过期缓存的周期,0表示不启动清理协程
+	stop       chan struct{} //关闭后清理协程退出
+	closed     sync.Once
+	OnEvicted  func(key string, value ByteView, reason eviction.Reason)
+	nget       atomic.Int64
+	nhit       atomic.Int64
+	nevict     atomic.Int64
 }
 
-func (c *cache) Add(key string, value ByteView) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.CacheBytes, nil)
+// 缓存的统计信息
+type CacheStats struct {
+	Items     int64 //缓存中的结点数
+	Bytes     int64 //已使用的字节数
+	Gets      int64 //查询次数
+	Hits      int64 //命中次数
+	Evictions int64 //因容量不足被淘汰的结点数
+}
+
+// 缓存分片
+type shard struct {
+	mutex  sync.Mutex
+	policy eviction.Policy
+}
+
+// 延迟初始化分片
+func (c *cache) lazyInit() {
+	c.once.Do(func() {
+		n := max(c.Shards, 1)
+		if c.CacheBytes > 0 {
+			n = int(min(int64(n), c.CacheBytes)) //每个分片至少1字节
+		}
+		onEvicted := func(key string, value eviction.Value, reason eviction.Reason) {
+			if reason == eviction.Capacity {
+				c.nevict.Add(1)
+			}
+			if c.OnEvicted != nil {
+				c.OnEvicted(key, value.(ByteView), reason)
+			}
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			c.shards[i] = &shard{policy: newPolicy(c.Policy, shardBytes(c.CacheBytes, n, i), onEvicted)}
+		}
+		c.stop = make(chan struct{})
+		if c.Interval > 0 {
+			go c.janitor()
+		}
+	})
+}
+
+// 选择key所在的分片
+func (c *cache) shard(key string) *shard {
+	c.lazyInit()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	//FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
 	}
-	c.lru.Add(key, value)
+	return c.shards[h%uint32(len(c.shards))]
 }
+
+// 后台协程,定期回收过期缓存
+func (c *cache) janitor() {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, s := range c.shards {
+			s.mutex.Lock()
+			s.policy.RemoveExpired()
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// 停止后台清理协程,之后过期缓存只在查询时惰性删除
+func (c *cache) Close() {
+	c.lazyInit()
+	c.closed.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *cache) Add(key string, value ByteView) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加带过期时间的缓存,ttl<=0表示永不过期
+func (c *cache) AddWithTTL(key string, value ByteView, ttl time.Duration) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.policy.AddWithTTL(key, value, ttl)
+}
+
+// 淘汰策略在命中时会调整结点顺序,因此查询也需要加互斥锁
 func (c *cache) Get(key string) (value ByteView, ok bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if c.lru == nil {
-		return ByteView{}, false
+	c.nget.Add(1)
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if value, ok := s.policy.Get(key); ok {
+		c.nhit.Add(1)
+		return value.(ByteView), true
 	}
-	if value, ok := c.lru.Get(key); ok {
+	return ByteView{}, false
+}
+
+// 查询key但不改变其在淘汰策略中的位置
+func (c *cache) Peek(key string) (value ByteView, ok bool) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if value, ok := s.policy.Peek(key); ok {
 		return value.(ByteView), true
 	}
 	return ByteView{}, false
 }
+
+func (c *cache) Contains(key string) bool {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Contains(key)
+}
+
+// 删除key,返回key是否存在
+func (c *cache) Remove(key string) bool {
+	s := c.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.policy.Remove(key)
+}
+
+// 返回所有未过期的key,同一分片内按淘汰顺序排列
+func (c *cache) Keys() []string {
+	keys := make([]string, 0)
+	c.Range(func(key string, value ByteView) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// 逐个分片遍历未过期的结点,同一分片内按淘汰顺序,fn返回false时停止
+// 遍历期间持有分片锁,fn中不能再访问缓存
+func (c *cache) Range(fn func(key string, value ByteView) bool) {
+	c.lazyInit()
+	next := true
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		s.policy.Range(func(key string, value eviction.Value) bool {
+			next = fn(key, value.(ByteView))
+			return next
+		})
+		s.mutex.Unlock()
+		if !next {
+			return
+		}
+	}
+}
+
+// 调整总容量并平均分配给各分片,返回因此被淘汰的结点数
+// 总容量小于分片数时每个分片保留1字节,实际容量为分片数
+func (c *cache) Resize(maxBytes int64) int {
+	c.lazyInit()
+	cnt := 0
+	for i, s := range c.shards {
+		s.mutex.Lock()
+		cnt += s.policy.Resize(shardBytes(maxBytes, len(c.shards), i))
+		s.mutex.Unlock()
+	}
+	return cnt
+}
+
+// 第i个分片的容量,总容量的余数分给前面的分片
+// 总容量大于0时每个分片至少1字节,避免容量为0被视为不限容量
+func shardBytes(total int64, n, i int) int64 {
+	if total <= 0 {
+		return total
+	}
+	b := total / int64(n)
+	if int64(i) < total%int64(n) {
+		b++
+	}
+	return max(b, 1)
+}
+
+// 清空缓存
+func (c *cache) Purge() {
+	c.lazyInit()
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		s.policy.Purge()
+		s.mutex.Unlock()
+	}
+}
+
+// 缓存中的结点数
+func (c *cache) Len() int {
+	c.lazyInit()
+	n := 0
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		n += s.policy.Len()
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// 已使用的字节数
+func (c *cache) Bytes() int64 {
+	c.lazyInit()
+	var n int64
+	for _, s := range c.shards {
+		s.mutex.Lock()
+		n += s.policy.Bytes()
+		s.mutex.Unlock()
+	}
+	return n
+}
+
+// 统计信息
+func (c *cache) Stats() CacheStats {
+	return CacheStats{
+		Items:     int64(c.Len()),
+		Bytes:     c.Bytes(),
+		Gets:      c.nget.Load(),
+		Hits:      c.nhit.Load(),
+		Evictions: c.nevict.Load(),
+	}
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	c := &cache{CacheBytes: 16 * 1024, Shards: 16}
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		c.Add(key, ByteView{b: []byte(key)})
+	}
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		if view, ok := c.Get(key); !ok || view.String() != key {
+			t.Fatalf("cache miss %s", key)
+		}
+	}
+	total := 0
+	for _, s := range c.shards {
+		if s.policy.Len() == 100 {
+			t.Fatalf("keys should spread across shards")
+		}
+		total += s.policy.Len()
+	}
+	if total != 100 {
+		t.Fatalf("expect 100 keys in all shards, got %d", total)
+	}
+}
+
+func TestSmallShards(t *testing.T) {
+	//容量小于分片数时减少分片,每个分片仍有容量上限
+	c := &cache{CacheBytes: 10, Shards: 16}
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		c.Add(key, ByteView{b: []byte("v")})
+	}
+	if len(c.shards) != 10 || c.Bytes() > 10 {
+		t.Fatalf("shards = %d, bytes = %d", len(c.shards), c.Bytes())
+	}
+	c.Resize(3)
+	if c.Bytes() > int64(len(c.shards)) {
+		t.Fatalf("resized cache should stay bounded, bytes = %d", c.Bytes())
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+	c := &cache{CacheBytes: 1024, Interval: time.Millisecond}
+	c.AddWithTTL("jack", ByteView{b: []byte("1")}, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if n := c.Len(); n != 0 {
+		t.Fatalf("janitor should remove expired keys, got %d", n)
+	}
+	//关闭后清理协程退出,过期缓存只在查询时删除
+	c.Close()
+	c.Close()
+	c.AddWithTTL("tom", ByteView{b: []byte("1")}, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if n := c.Len(); n != 1 {
+		t.Fatalf("janitor should stop after close, got %d keys", n)
+	}
+	if _, ok := c.Get("tom"); ok || c.Len() != 0 {
+		t.Fatalf("expired key should be removed on get")
+	}
+}
+
+func TestCacheRemove(t *testing.T) {
+	c := &cache{CacheBytes: 1024, Shards: 4}
+	for _, key := range []string{"jack", "tom", "lucy"} {
+		c.Add(key, ByteView{b: []byte(key)})
+	}
+	if !c.Remove("tom") || c.Contains("tom") || c.Len() != 2 {
+		t.Fatalf("Remove tom failed")
+	}
+	if view, ok := c.Peek("jack"); !ok || view.String() != "jack" {
+		t.Fatalf("Peek jack failed")
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Fatalf("Keys should have 2 keys, got %v", keys)
+	}
+	if n := c.Resize(4); n != 2 || c.Len() != 0 {
+		t.Fatalf("Resize should evict all keys, %d evicted", n)
+	}
+	c.Resize(1024)
+	c.Add("jack", ByteView{b: []byte("jack")})
+	c.Purge()
+	if c.Len() != 0 {
+		t.Fatalf("Purge failed")
+	}
+}
+
+func TestShardsConcurrent(t *testing.T) {
+	c := &cache{CacheBytes: 1024, Shards: 8}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				key := strconv.Itoa(i*1000 + j)
+				c.Add(key, ByteView{b: []byte(key)})
+				c.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// 使用 go test -bench Parallel -cpu 1,2,4,8 观察吞吐量随GOMAXPROCS的变化
+func BenchmarkCacheParallel(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	var offset atomic.Int64
+	for _, n := range []int{1, 4, 16, 64} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) {
+			c := &cache{CacheBytes: 1 << 20, Shards: n}
+			for _, key := range keys {
+				c.Add(key, ByteView{b: []byte(key)})
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				//各协程从不同位置开始访问,避免同时竞争同一分片
+				i := int(offset.Add(97))
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%10 == 0 {
+						c.Add(key, ByteView{b: []byte(key)})
+					} else {
+						c.Get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// 比较各节点放置算法的负载均衡程度与节点变化时移动的key的比例
+//
+// 用法: go run ./cmd/placement-report -nodes 10 -keys 100000
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/placement"
+)
+
+var (
+	nodeCount = flag.Int("nodes", 10, "number of nodes")
+	keyCount  = flag.Int("keys", 100000, "number of keys")
+)
+
+func nodes(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("http://10.0.0.%d:8000", i+1)
+	}
+	return nodes
+}
+
+func keys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// 最大负载/平均负载与负载的变异系数
+func balance(p placement.Placement, keys []string) (peak, cv float64) {
+	counts := make(map[string]float64)
+	for _, key := range keys {
+		counts[p.Get(key)]++
+	}
+	members := p.Members()
+	avg := float64(len(keys)) / float64(len(members))
+	variance := 0.0
+	for _, node := range members {
+		peak = max(peak, counts[node])
+		variance += (counts[node] - avg) * (counts[node] - avg)
+	}
+	return peak / avg, math.Sqrt(variance/float64(len(members))) / avg
+}
+
+// 节点变化前后归属改变的key的比例
+func moved(before, after placement.Placement, keys []string) float64 {
+	cnt := 0
+	for _, key := range keys {
+		if before.Get(key) != after.Get(key) {
+			cnt++
+		}
+	}
+	return float64(cnt) / float64(len(keys))
+}
+
+func build(algo placement.Algorithm, nodes []string) placement.Placement {
+	p := placement.New(algo)
+	p.Add(nodes...)
+	return p
+}
+
+func main() {
+	flag.Parse()
+	n := max(*nodeCount, 2)
+	all, keys := nodes(n+1), keys(*keyCount)
+	base := all[:n]
+	//删除中间的节点
+	removed := append(append([]string{}, base[:n/2]...), base[n/2+1:]...)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "algorithm\tmax/avg\tcv\tmoved(add)\tmoved(remove)\tns/get\t\n")
+	for _, algo := range []placement.Algorithm{placement.Ring, placement.Rendezvous, placement.Jump, placement.Maglev} {
+		p := build(algo, base)
+		peak, cv := balance(p, keys)
+		add := moved(p, build(algo, all), keys)
+		remove := moved(p, build(algo, removed), keys)
+		start := time.Now()
+		for _, key := range keys {
+			p.Get(key)
+		}
+		ns := float64(time.Since(start).Nanoseconds()) / float64(len(keys))
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.2f%%\t%.2f%%\t%.0f\t\n", algo, peak, cv, add*100, remove*100, ns)
+	}
+	fmt.Fprintf(w, "ideal\t1.000\t0.000\t%.2f%%\t%.2f%%\t\t\n", 100/float64(n+1), 100/float64(n))
+	w.Flush()
+}
@@ -2,6 +2,7 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"slices"
 	"sort"
 	"strconv"
@@ -12,9 +13,10 @@ type Hash func([]byte) uint32
 // 存储所有的hash keys
 type Map struct {
 	hash     Hash              //Hash函数
-	replicas int               //虚拟节点数
+	replicas int               //权重为1的节点的虚拟节点数
 	keys     []uint32          //sorted
 	hashMap  map[uint32]string //虚拟节点与真实节点的映射表
+	weights  map[string]int    //真实节点的权重
 }
 
 // 创建Map实例
@@ -24,6 +26,7 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		keys:     make([]uint32, 0),
 		hashMap:  make(map[uint32]string),
+		weights:  make(map[string]int),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -31,16 +34,84 @@ func New(replicas int, fn Hash) *Map {
 	return m
 }
 
-// 添加真实节点的方法
+// 添加真实节点的方法,权重为1,已存在的节点权重重置为1
 func (m *Map) Add(keys ...string) {
 	for _, key := range keys {
-		for i := range m.replicas {
+		m.add(key, 1)
+	}
+	slices.Sort(m.keys)
+}
+
+// 添加带权重的真实节点,虚拟节点数为replicas*weight,节点分到的key的比例与权重成正比
+// 已存在的节点会按新的权重重建虚拟节点,weight<=0时删除节点
+func (m *Map) AddWithWeight(key string, weight int) {
+	m.Remove(key)
+	if weight > 0 {
+		m.add(key, weight)
+		slices.Sort(m.keys)
+	}
+}
+
+// 修改节点的权重,节点不存在时返回false
+func (m *Map) SetWeight(key string, weight int) bool {
+	if _, ok := m.weights[key]; !ok {
+		return false
+	}
+	m.AddWithWeight(key, weight)
+	return true
+}
+
+// 节点的权重,节点不存在时返回0
+func (m *Map) Weight(key string) int {
+	return m.weights[key]
+}
+
+// 添加虚拟节点,调用者负责排序
+func (m *Map) add(key string, weight int) {
+	if _, ok := m.weights[key]; ok {
+		m.Remove(key)
+	}
+	m.weights[key] = weight
+	for i := range m.replicas * weight {
+		hash := m.hash([]byte(strconv.FormatInt(int64(i), 10) + key))
+		m.hashMap[hash] = key
+		m.keys = append(m.keys, hash)
+	}
+}
+
+// 删除真实节点及其所有虚拟节点
+func (m *Map) Remove(keys ...string) {
+	removed := false
+	for _, key := range keys {
+		weight, ok := m.weights[key]
+		if !ok {
+			continue
+		}
+		for i := range m.replicas * weight {
 			hash := m.hash([]byte(strconv.FormatInt(int64(i), 10) + key))
-			m.hashMap[hash] = key
-			m.keys = append(m.keys, hash)
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+			}
 		}
+		delete(m.weights, key)
+		removed = true
+	}
+	if removed {
+		m.keys = slices.DeleteFunc(m.keys, func(hash uint32) bool {
+			_, ok := m.hashMap[hash]
+			return !ok
+		})
 	}
-	slices.Sort(m.keys)
+}
+
+// 返回所有真实节点,按名称排序
+func (m *Map) Members() []string {
+	members := make([]string, 0, len(m.weights))
+	for key := range m.weights {
+		members = append(members, key)
+	}
+	slices.Sort(members)
+	return members
 }
 
 // 得到输入key值对应的真实节点名称
@@ -48,9 +119,54 @@ func (m *Map) Get(key string) string {
 	if len(m.keys) == 0 {
 		return ""
 	}
+	return m.hashMap[m.keys[m.search(key)]]
+}
+
+// key在哈希环上顺时针方向的第一个虚拟节点的下标
+func (m *Map) search(key string) int {
 	hash := m.hash([]byte(key))
 	idx := sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= hash
 	})
-	return m.hashMap[m.keys[idx%len(m.keys)]]
+	return idx % len(m.keys)
+}
+
+// 得到key在哈希环上顺时针方向的前n个不同真实节点,第一个为Get返回的节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	idx := m.search(key)
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// 有界负载的一致性哈希(Consistent Hashing with Bounded Loads)
+// 每个节点的负载上限为ceil((1+epsilon)*(total+1)*权重占比),total为所有节点当前的负载之和
+// 从key的位置顺时针查找第一个负载未达上限的节点,热点key因此会溢出到后继节点
+func (m *Map) GetBounded(key string, load func(node string) int64, epsilon float64) string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+	var total int64
+	weights := 0
+	for node, weight := range m.weights {
+		total += load(node)
+		weights += weight
+	}
+	idx := m.search(key)
+	for i := range len(m.keys) {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		limit := math.Ceil((1 + epsilon) * float64(total+1) * float64(m.weights[node]) / float64(weights))
+		if float64(load(node)+1) <= limit {
+			return node
+		}
+	}
+	return m.hashMap[m.keys[idx]]
 }
@@ -1,6 +1,8 @@
 package consistenthash
 
 import (
+	"math/rand"
+	"slices"
 	"strconv"
 	"testing"
 )
@@ -41,3 +43,178 @@ func TestHashing(t *testing.T) {
 	}
 
 }
+
+func TestRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.ParseInt(string(key), 10, 32)
+		return uint32(i)
+	})
+	hash.Add("6", "4", "2", "8")
+	if members := hash.Members(); !slices.Equal(members, []string{"2", "4", "6", "8"}) {
+		t.Fatalf("unexpected members %v", members)
+	}
+
+	// Removes 8, 18, 28, 27 should map back to 2.
+	hash.Remove("8")
+	testCases := map[string]string{
+		"2":  "2",
+		"11": "2",
+		"23": "4",
+		"27": "2",
+	}
+	for k, v := range testCases {
+		if hash.Get(k) != v {
+			t.Errorf("Asking for %s, should have yielded %s", k, v)
+		}
+	}
+	if members := hash.Members(); !slices.Equal(members, []string{"2", "4", "6"}) {
+		t.Fatalf("unexpected members %v", members)
+	}
+
+	hash.Remove("2", "4", "6", "unknown")
+	if hash.Get("11") != "" || len(hash.Members()) != 0 {
+		t.Fatalf("all nodes should be removed")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.ParseInt(string(key), 10, 32)
+		return uint32(i)
+	})
+	// 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+
+	testCases := map[string][]string{
+		"2":  {"2", "4"},
+		"11": {"2", "4"},
+		"23": {"4", "6"},
+		"27": {"2", "4"},
+	}
+	for k, v := range testCases {
+		if got := hash.GetN(k, 2); !slices.Equal(got, v) || got[0] != hash.Get(k) {
+			t.Errorf("Asking for %s, should have yielded %v, got %v", k, v, got)
+		}
+	}
+	if got := hash.GetN("5", 5); !slices.Equal(got, []string{"6", "2", "4"}) {
+		t.Errorf("n larger than nodes should yield all nodes, got %v", got)
+	}
+	if got := New(3, nil).GetN("5", 2); len(got) != 0 {
+		t.Errorf("empty ring should yield nothing, got %v", got)
+	}
+}
+
+// 统计每个节点分到的key的比例
+func shares(m *Map, n int) map[string]float64 {
+	counts := make(map[string]float64)
+	for i := range n {
+		counts[m.Get("key"+strconv.Itoa(i))]++
+	}
+	for node := range counts {
+		counts[node] /= float64(n)
+	}
+	return counts
+}
+
+func TestWeight(t *testing.T) {
+	const keys, tolerance = 100000, 0.2
+	hash := New(100, nil)
+	weights := map[string]int{"A": 1, "B": 2, "C": 3}
+	for node, weight := range weights {
+		hash.AddWithWeight(node, weight)
+	}
+	check := func() {
+		t.Helper()
+		total := 0
+		for _, weight := range weights {
+			total += weight
+		}
+		for node, share := range shares(hash, keys) {
+			want := float64(weights[node]) / float64(total)
+			if share < want*(1-tolerance) || share > want*(1+tolerance) {
+				t.Errorf("node %s with weight %d got share %.3f, want %.3f", node, weights[node], share, want)
+			}
+		}
+	}
+	check()
+
+	//运行时调整权重
+	weights["A"] = 3
+	if !hash.SetWeight("A", 3) || hash.Weight("A") != 3 {
+		t.Fatalf("set weight of A failed")
+	}
+	check()
+	if hash.SetWeight("D", 1) {
+		t.Fatalf("set weight of unknown node should fail")
+	}
+
+	//权重为0时删除节点
+	hash.AddWithWeight("A", 0)
+	delete(weights, "A")
+	if slices.Contains(hash.Members(), "A") || len(hash.keys) != 500 {
+		t.Fatalf("node A should be removed, members %v", hash.Members())
+	}
+	check()
+}
+
+// 模拟热点key的请求流: 始终保持inflight个进行中的请求,每步完成最早的请求并分配一个新请求
+// 返回整个过程中 最大节点负载/平均负载 的最大值
+func simulate(m *Map, pick func(key string, load func(string) int64) string) float64 {
+	const inflight, steps = 600, 20000
+	nodes := m.Members()
+	loads := make(map[string]int64)
+	load := func(node string) int64 {
+		return loads[node]
+	}
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.2, 1, 10000)
+	queue := make([]string, 0, inflight)
+	ratio := 0.0
+	for i := range steps {
+		if len(queue) == inflight {
+			loads[queue[0]]--
+			queue = queue[1:]
+		}
+		node := pick("key"+strconv.FormatUint(zipf.Uint64(), 10), load)
+		loads[node]++
+		queue = append(queue, node)
+		if i >= inflight {
+			peak := int64(0)
+			for _, node := range nodes {
+				peak = max(peak, loads[node])
+			}
+			ratio = max(ratio, float64(peak)*float64(len(nodes))/float64(len(queue)))
+		}
+	}
+	return ratio
+}
+
+func TestBoundedLoad(t *testing.T) {
+	const epsilon = 0.25
+	hash := New(50, nil)
+	for i := range 8 {
+		hash.Add("node" + strconv.Itoa(i))
+	}
+	plain := simulate(hash, func(key string, load func(string) int64) string {
+		return hash.Get(key)
+	})
+	bounded := simulate(hash, func(key string, load func(string) int64) string {
+		return hash.GetBounded(key, load, epsilon)
+	})
+	t.Logf("max/avg load ratio: plain %.2f, bounded %.2f", plain, bounded)
+	//上限取整带来的误差不超过每个节点一个请求
+	if bounded > 1+epsilon+8.0/600 {
+		t.Fatalf("bounded load ratio %.2f exceeds 1+epsilon", bounded)
+	}
+	if plain <= bounded {
+		t.Fatalf("hot keys should overload plain consistent hashing")
+	}
+
+	//没有负载时与Get结果一致
+	idle := func(string) int64 { return 0 }
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		if hash.GetBounded(key, idle, epsilon) != hash.Get(key) {
+			t.Fatalf("idle ring should route %s to its owner", key)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// 缓存淘汰策略的公共定义
+package eviction
+
+import "time"
+
+// 实现了Value接口的值都可被缓存接受
+type Value interface {
+	Len() int
+}
+
+// 淘汰原因
+type Reason int
+
+const (
+	Capacity Reason = iota //容量不足被淘汰
+	Expired                //过期被淘汰
+	Removed                //被显式删除
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Capacity:
+		return "capacity"
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// 淘汰回调函数
+type EvictedFunc func(key string, value Value, reason Reason)
+
+// 淘汰策略,实现均不保证并发安全,由调用方加锁
+// Keys与Range按淘汰顺序遍历,即先返回最先被淘汰的结点,已过期的结点会被跳过
+type Policy interface {
+	Get(key string) (value Value, ok bool)
+	Peek(key string) (value Value, ok bool) //查询但不改变访问顺序与频率
+	Contains(key string) bool
+	Add(key string, value Value)
+	AddWithTTL(key string, value Value, ttl time.Duration)
+	Remove(key string) bool
+	RemoveExpired() int
+	Keys() []string
+	Range(fn func(key string, value Value) bool) //fn返回false时停止遍历
+	Resize(maxBytes int64) int                   //调整容量,返回因此淘汰的结点数
+	Purge()
+	Len() int
+	Bytes() int64 //已使用的字节数
+}
+
+// 根据ttl计算过期时间,ttl<=0返回零值表示永不过期
+func Deadline(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// 判断过期时间expire在now时刻是否已过期
+func IsExpired(expire time.Time, now time.Time) bool {
+	return !expire.IsZero() && now.After(expire)
+}
@@ -1,11 +1,20 @@
 package cache
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/LudensCS/Cache/cache/cachepb"
+	"github.com/LudensCS/Cache/cache/eviction"
 	"github.com/LudensCS/Cache/cache/singleflight"
+	"github.com/LudensCS/Cache/cache/tinylfu"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -20,32 +29,239 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// 支持context的数据源,调用方的超时与取消会传递到数据源
+type ContextGetter interface {
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// 同时实现Getter接口,可直接传给NewGroup
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+// 将Getter适配为ContextGetter,不支持context的数据源只在调用前检查ctx是否已结束
+func contextGetter(getter Getter) ContextGetter {
+	if cg, ok := getter.(ContextGetter); ok {
+		return cg
+	}
+	return ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return getter.Get(key)
+	})
+}
+
+// 支持批量加载的数据源,返回的值与错误均与keys一一对应
+// Group.GetMany在Getter同时实现了该接口时使用批量加载
+type BatchGetter interface {
+	GetMany(ctx context.Context, keys []string) ([][]byte, []error)
+}
+
 // 缓存组,一个缓存组可以有多个分布式节点
 // 同名缓存组共享逻辑地址,如果两个节点在同名缓存组内,它们属于同一个子系统
 type Group struct {
 	name      string
 	getter    Getter //回调函数
+	cgetter   ContextGetter
 	mainCache cache
 	peers     PeerPicker
 	loader    *singleflight.Group //利用singleflight保证同一时间每种请求只会访问数据库一次
+	ttl       time.Duration       //缓存默认过期时间,0表示永不过期
+	replicas  int                 //每个key保存在哈希环上的节点数,<=1表示只保存在所属节点
+	push      bool                //从数据源加载后是否推送到副本节点
+	hotCache  cache               //缓存从远端节点获取的热点数据,容量为0时不启用
+	hotRatio  int                 //从远端节点获取的值以1/hotRatio的概率放入热点缓存
+	hotMutex  sync.Mutex
+	hotSketch *tinylfu.Sketch //统计从远端节点获取的频率,为nil时按概率放入热点缓存
+	hotLimit  int             //放入热点缓存所需的最小频率
+	negCache  cache           //缓存数据源中不存在的key,容量为0时不启用
+	negTTL    time.Duration   //不存在的key的缓存时间
+	stale     time.Duration   //软过期后仍可返回旧值的时间
+	beta      float64         //提前刷新系数,0表示不提前刷新
+	refreshes sync.Map        //正在后台刷新的key
+	grace     cache           //保留过期与被淘汰的值,加载失败时返回,容量为0时不启用
+	maxStale  time.Duration   //宽限区中的值过期后最多保留的时间
+	write     writeMode       //Set与Delete是否写入数据源
+	setter    Setter
+	deleter   Deleter
+	writer    *writeBuffer //write-behind的待写入队列
+	leases    *leaseTable  //本节点所属key的租约,为nil时不启用
+	stats     groupStats
+	logger    *slog.Logger
+	sampler   logSampler //热点路径的日志采样
+}
+
+// NewGroup的可选配置项
+type GroupOption func(*Group)
+
+// 设置组内缓存的默认过期时间,并按该周期在后台回收过期缓存
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+		g.mainCache.Interval = ttl
+	}
+}
+
+// 将组内缓存分为n个独立加锁的分片,容量平均分配,默认不分片,容量小于分片数时减少分片
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.Shards = n
+	}
+}
+
+// 设置组内缓存的淘汰策略,默认为LRU
+func WithPolicy(p EvictionPolicy) GroupOption {
+	return func(g *Group) {
+		g.mainCache.Policy = p
+	}
+}
+
+// 每个key保存在哈希环上顺时针方向的n个不同节点上,第一个为所属节点,其余为副本
+// 所属节点不可用时依次从副本节点读取,写入与删除同时作用于所有副本,需要PeerPicker实现ReplicaPicker
+func WithReplicas(n int) GroupOption {
+	return func(g *Group) {
+		g.replicas = n
+	}
+}
+
+// 从数据源加载数据后异步推送到副本节点,使所属节点故障时副本节点已有数据
+func WithReplicaPush() GroupOption {
+	return func(g *Group) {
+		g.push = true
+	}
+}
+
+// 为属于远端节点的key启用容量为maxBytes的热点缓存,与主缓存分开淘汰,使用组的过期时间
+// 默认从远端节点获取的值以1/10的概率放入热点缓存,全局热点key因此不必每次都访问所属节点
+// Group.Set与Group.Delete随后使所有远端节点上key的副本失效,集群内所有节点应使用相同的配置
+func WithHotCache(maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.hotCache.CacheBytes = maxBytes
+	}
 }
 
+// 只有近期从远端节点获取次数达到threshold的key才放入热点缓存,频率由count-min sketch估计
+// sketch的计数上限为15,threshold应在1~15之间,需要同时使用WithHotCache
+func WithHotKeyThreshold(threshold int) GroupOption {
+	return func(g *Group) {
+		g.hotLimit = threshold
+	}
+}
+
+// 设置组的日志,日志自动带有group属性,默认使用slog.Default()
+// 传入DiscardLogger可关闭日志
+func WithLogger(logger *slog.Logger) GroupOption {
+	return func(g *Group) {
+		g.logger = logger
+	}
+}
+
+// 命中、未命中等每个请求都会产生的Debug日志每n条只记录一条,默认为100,n<=1时全部记录
+func WithLogSampling(n int) GroupOption {
+	return func(g *Group) {
+		g.sampler.n = int64(n)
+	}
+}
+
+// 缓存数据源中不存在的key,在ttl内再次查询时直接返回ErrNotFound而不访问数据源
+// 不存在的key使用容量为maxBytes的独立缓存,只占用key的字节数,不会挤占主缓存
+func WithNegativeCache(ttl time.Duration, maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negTTL = ttl
+		g.negCache.CacheBytes = maxBytes
+	}
+}
+
+// 值在组的过期时间(WithTTL)后软过期,之后的stale时间内查询立即返回旧值,并通过singleflight在后台刷新一次
+// 超过stale时间后值才真正过期,查询阻塞等待加载,需要同时使用WithTTL
+func WithStaleWhileRevalidate(stale time.Duration) GroupOption {
+	return func(g *Group) {
+		g.stale = stale
+	}
+}
+
+// 按XFetch算法在软过期前概率性地提前刷新: 距离软过期越近、上次加载越慢、beta越大,越可能提前刷新
+// beta通常取1,需要同时使用WithTTL
+func WithRefreshAhead(beta float64) GroupOption {
+	return func(g *Group) {
+		g.beta = beta
+	}
+}
+
+// 过期或因容量不足被淘汰的值移入容量为maxBytes的宽限区,数据源或远端节点加载失败时返回宽限区中的旧值,
+// 返回值的Stale()为true;值过期超过maxStale后不再返回,显式删除或失效的值不会进入宽限区
+func WithStaleIfError(maxStale time.Duration, maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.maxStale = maxStale
+		g.grace.CacheBytes = maxBytes
+	}
+}
+
+// Getter返回该错误(或包装了该错误的错误)表示key不存在,与其他加载失败区分
+// Group查询不存在的key时返回该错误,远端节点以codes.NotFound返回
+var ErrNotFound = errors.New("key not found")
+
+// 缓存类型
+type CacheType int
+
+const (
+	MainCache     CacheType = iota + 1 //本节点所属的key
+	HotCache                           //远端节点所属的热点key
+	NegativeCache                      //不存在的key
+	GraceCache                         //加载失败时可返回的过期旧值
+)
+
+const (
+	pushTimeout     = 5 * time.Second  //副本推送的超时时间
+	refreshTimeout  = 10 * time.Second //后台刷新的超时时间
+	defaultHotRatio = 10
+)
+
 var (
 	mu     sync.RWMutex
 	groups = make(map[string]*Group)
 )
 
 // 实例化Gruop对象
-func NewGroup(name string, CacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, CacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("getter is nil!")
 	}
 	g := &Group{
 		name:      name,
 		getter:    getter,
+		cgetter:   contextGetter(getter),
 		mainCache: cache{CacheBytes: CacheBytes},
 		loader:    &singleflight.Group{},
+		logger:    slog.Default(),
+		sampler:   logSampler{n: defaultLogSampling},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.logger = g.logger.With("group", name)
+	if g.hotCache.CacheBytes > 0 {
+		g.hotCache.Interval, g.hotRatio = g.mainCache.Interval, defaultHotRatio
+		if g.hotLimit > 0 {
+			g.hotSketch = tinylfu.NewSketch(max(int(g.hotCache.CacheBytes/64), 1024))
+		}
+	}
+	if g.negCache.CacheBytes > 0 {
+		g.negCache.Interval = g.negTTL
+	}
+	if g.grace.CacheBytes > 0 && g.maxStale > 0 {
+		g.grace.Interval = g.maxStale
+		//热点缓存保存远端节点所属的值,所属节点故障时同样可以返回旧值
+		g.mainCache.OnEvicted, g.hotCache.OnEvicted = g.retain, g.retain
 	}
+	g.initWriter()
 	mu.Lock()
 	defer mu.Unlock()
 	groups[name] = g
@@ -70,30 +286,91 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
+// 停止缓存组的后台协程(过期缓存清理与write-behind刷新)并将剩余的写入刷新到数据源,服务关闭前调用
+// 之后仍可查询,过期缓存在查询时惰性删除
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mainCache.Close()
+	g.hotCache.Close()
+	g.negCache.Close()
+	g.grace.Close()
+	if g.writer == nil {
+		return nil
+	}
+	g.writer.stop.Do(func() {
+		close(g.writer.done)
+	})
+	<-g.writer.stopped
+	return g.writer.flush(ctx)
+}
+
 // 查询key对应的value
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// 查询key对应的value,ctx的超时与取消会传递到远端节点与数据源
+func (g *Group) GetContext(ctx context.Context, key string) (value ByteView, err error) {
 	if key == "" {
 		return ByteView{}, status.Errorf(codes.Internal, "key is required")
 	}
-	if value, ok := g.mainCache.Get(key); ok {
-		log.Println("Cache hit")
+	ctx, span := g.startSpan(ctx, "Group.Get", attribute.String("key", key))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	g.stats.gets.Add(1)
+	if value, ok := g.lookup(key); ok {
+		g.stats.hits.Add(1)
+		span.SetAttributes(attribute.String("cache", "hit"))
+		g.sampler.debug(g.logger, "cache hit", "key", key)
+		if g.maybeRefresh(key, value) {
+			span.SetAttributes(attribute.Bool("stale", true))
+			value.stale = true
+		}
 		return value, nil
 	}
-	return g.Load(key)
+	if g.isNegative(key) {
+		g.stats.hits.Add(1)
+		g.stats.negativeHits.Add(1)
+		span.SetAttributes(attribute.String("cache", "negative"))
+		return ByteView{}, ErrNotFound
+	}
+	g.stats.misses.Add(1)
+	span.SetAttributes(attribute.String("cache", "miss"))
+	g.sampler.debug(g.logger, "cache miss", "key", key)
+	return g.Load(ctx, key)
 }
 
-// 尝试从远端节点获取缓存,失败则调用GetLocally方法,利用singleflight防止缓存击穿
-func (g *Group) Load(key string) (ByteView, error) {
-	value, err := g.loader.Do(key, func() (value any, err error) {
-		if g.peers != nil {
-			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err = g.GetFromPeer(peer, key); err == nil {
-					return value, nil
-				}
-				log.Println("[Cache] failed to get from peer :", err)
+// 尝试从远端节点获取缓存,所属节点失败时依次尝试副本节点,均失败则调用GetLocally方法
+// 利用singleflight防止缓存击穿,并发的相同请求共享第一个请求的ctx
+// 来自远端节点的请求只在本节点处理,不再转发
+func (g *Group) Load(ctx context.Context, key string) (ByteView, error) {
+	value, err := g.do(key, func() (any, error) {
+		if peer, ok := g.pickPeer(ctx, key); ok {
+			value, err := g.getFromReplicas(ctx, peer, key)
+			if err == nil {
+				g.populateHot(key, value)
+				return value, nil
+			}
+			if errors.Is(err, ErrNotFound) {
+				return nil, ErrNotFound
+			}
+			g.logger.Warn("failed to get from peer, load locally", "key", key, "peer", peerAddr(peer), "err", err)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
 			}
 		}
-		return g.GetLocally(key)
+		value, err := g.GetLocally(ctx, key)
+		if err != nil {
+			if value, ok := g.serveStale(ctx, key, err); ok {
+				return value, nil
+			}
+			return nil, err
+		}
+		if g.push {
+			g.pushToReplicas(key, value)
+		}
+		return value, nil
 	})
 	if err != nil {
 		return ByteView{}, err
@@ -101,28 +378,679 @@ func (g *Group) Load(key string) (ByteView, error) {
 	return value.(ByteView), nil
 }
 
+// 批量查询key对应的value,返回的值与错误均与keys一一对应
+func (g *Group) GetMany(keys []string) ([]ByteView, []error) {
+	return g.GetManyContext(context.Background(), keys)
+}
+
+// 批量查询key对应的value,返回的值与错误均与keys一一对应
+// 未命中的key按所属节点分组,每个远端节点只发起一次rpc,本节点的key通过数据源加载
+func (g *Group) GetManyContext(ctx context.Context, keys []string) ([]ByteView, []error) {
+	ctx, span := g.startSpan(ctx, "Group.GetMany", attribute.Int("keys", len(keys)))
+	defer span.End()
+	values := make([]ByteView, len(keys))
+	errs := make([]error, len(keys))
+	index := make(map[string][]int) //未命中的key及其在keys中的位置
+	for i, key := range keys {
+		if key == "" {
+			errs[i] = status.Errorf(codes.InvalidArgument, "key is required")
+			continue
+		}
+		g.stats.gets.Add(1)
+		if value, ok := g.lookup(key); ok {
+			g.stats.hits.Add(1)
+			value.stale = g.maybeRefresh(key, value)
+			values[i] = value
+		} else if g.isNegative(key) {
+			g.stats.hits.Add(1)
+			g.stats.negativeHits.Add(1)
+			errs[i] = ErrNotFound
+		} else {
+			g.stats.misses.Add(1)
+			index[key] = append(index[key], i)
+		}
+	}
+	set := func(key string, value ByteView, err error) {
+		for _, i := range index[key] {
+			values[i], errs[i] = value, err
+		}
+	}
+
+	var local []string
+	remote := make(map[PeerGetter][]string)
+	for key := range index {
+		if peer, ok := g.pickPeer(ctx, key); ok {
+			remote[peer] = append(remote[peer], key)
+		} else {
+			local = append(local, key)
+		}
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for peer, batch := range remote {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			Resp, err := peer.GetBatch(ctx, &cachepb.BatchRequest{Group: g.name, Keys: batch})
+			observeLoad(g.name, "peer_batch", start)
+			if err != nil {
+				g.stats.peerErrors.Add(int64(len(batch)))
+				g.logger.Warn("failed to get batch from peer", "keys", len(batch), "peer", peerAddr(peer), "err", err)
+				if ctx.Err() != nil {
+					for _, key := range batch {
+						set(key, ByteView{}, ctx.Err())
+					}
+					return
+				}
+				mutex.Lock()
+				local = append(local, batch...)
+				mutex.Unlock()
+				return
+			}
+			for _, key := range batch {
+				set(key, ByteView{}, status.Errorf(codes.Internal, "%s missing in batch response", key))
+			}
+			for _, r := range Resp.GetResults() {
+				if r.GetNotFound() {
+					g.stats.peerLoads.Add(1)
+					set(r.GetKey(), ByteView{}, ErrNotFound)
+				} else if r.GetError() != "" {
+					g.stats.peerErrors.Add(1)
+					set(r.GetKey(), ByteView{}, errors.New(r.GetError()))
+				} else {
+					g.stats.peerLoads.Add(1)
+					value := ByteView{b: CloneBytes(r.GetValue()), stale: r.GetStale()}
+					g.populateHot(r.GetKey(), value)
+					set(r.GetKey(), value, nil)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	g.getManyLocally(ctx, local, set)
+	for key, index := range index {
+		if err := errs[index[0]]; err != nil {
+			if value, ok := g.serveStale(ctx, key, err); ok {
+				set(key, value, nil)
+			}
+		}
+	}
+	return values, errs
+}
+
+// 从本地数据源加载多个key并写入缓存,数据源支持批量加载时只调用一次
+func (g *Group) getManyLocally(ctx context.Context, keys []string, set func(string, ByteView, error)) {
+	if len(keys) == 0 {
+		return
+	}
+	load := func(key string) {
+		value, err := g.do(key, func() (any, error) {
+			return g.GetLocally(ctx, key)
+		})
+		if err != nil {
+			set(key, ByteView{}, err)
+		} else {
+			set(key, value.(ByteView), nil)
+		}
+	}
+	if g.writer != nil {
+		//有未刷新写入的key不从数据源批量加载
+		keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+			if _, ok := g.writer.get(key); ok {
+				load(key)
+				return true
+			}
+			return false
+		})
+		if len(keys) == 0 {
+			return
+		}
+	}
+	if isPeerRequest(ctx) {
+		//溢出到本节点的key逐个加载,不放入主缓存
+		keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+			if g.overflowed(ctx, key) {
+				load(key)
+				return true
+			}
+			return false
+		})
+		if len(keys) == 0 {
+			return
+		}
+	}
+	bg, ok := g.getter.(BatchGetter)
+	if !ok {
+		for _, key := range keys {
+			load(key)
+		}
+		return
+	}
+	//启用租约时获取所有key的租约,其他调用方正在回填的key与批量加载同时等待其结果,共用同一截止时间
+	tokens := make([]uint64, 0, len(keys))
+	if g.leases != nil {
+		var wg sync.WaitGroup
+		expired, stop := g.leaseDeadline()
+		defer stop()
+		defer wg.Wait()
+		batch := make([]string, 0, len(keys))
+		for _, key := range keys {
+			token, filled := g.acquireLease(key)
+			if token == 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := g.awaitFillUntil(ctx, key, filled, expired)
+					set(key, value, err)
+				}()
+				continue
+			}
+			batch, tokens = append(batch, key), append(tokens, token)
+		}
+		if keys = batch; len(keys) == 0 {
+			return
+		}
+	} else {
+		tokens = tokens[:len(keys)]
+	}
+	start := time.Now()
+	spanCtx, span := g.startSpan(ctx, "Group.GetManyLocally", attribute.Int("keys", len(keys)))
+	values, errs := bg.GetMany(spanCtx, keys)
+	span.End()
+	observeLoad(g.name, "local_batch", start)
+	for i, key := range keys {
+		switch {
+		case i >= len(values):
+			g.releaseLease(key, tokens[i])
+			g.stats.loadErrors.Add(1)
+			set(key, ByteView{}, status.Errorf(codes.Internal, "batch getter returned %d values for %d keys", len(values), len(keys)))
+		case i < len(errs) && errors.Is(errs[i], ErrNotFound):
+			g.stats.localLoads.Add(1)
+			g.fill(key, tokens[i], func() { g.populateNegative(key) })
+			set(key, ByteView{}, ErrNotFound)
+		case i < len(errs) && errs[i] != nil:
+			g.releaseLease(key, tokens[i])
+			g.stats.loadErrors.Add(1)
+			set(key, ByteView{}, errs[i])
+		default:
+			g.stats.localLoads.Add(1)
+			value := ByteView{b: CloneBytes(values[i])}
+			g.fill(key, tokens[i], func() { g.PopulateCache(key, value) })
+			set(key, value, nil)
+		}
+	}
+}
+
+// 从所属节点获取缓存,失败时按环上顺序依次尝试其余副本节点
+func (g *Group) getFromReplicas(ctx context.Context, owner PeerGetter, key string) (ByteView, error) {
+	value, err := g.GetFromPeer(ctx, owner, key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+	replicas, _ := g.pickReplicas(key)
+	last := owner
+	for _, peer := range replicas {
+		if peer == owner {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		g.logger.Warn("failed to get from peer, try replica", "key", key, "peer", peerAddr(last), "err", err)
+		if value, err = g.GetFromPeer(ctx, peer, key); err == nil || errors.Is(err, ErrNotFound) {
+			return value, err
+		}
+		last = peer
+	}
+	return ByteView{}, err
+}
+
+// 异步将value推送到key的远端副本节点
+func (g *Group) pushToReplicas(key string, value ByteView) {
+	replicas, _ := g.pickReplicas(key)
+	if len(replicas) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		Req := &cachepb.PutRequest{Group: g.name, Key: key, Value: value.ByteSlice()}
+		for _, peer := range replicas {
+			if _, err := peer.Put(ctx, Req); err != nil {
+				g.logger.Warn("failed to push to replica", "key", key, "peer", peerAddr(peer), "err", err)
+			}
+		}
+	}()
+}
+
 // 从远端节点获取缓存
-func (g *Group) GetFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) GetFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
+	defer observeLoad(g.name, "peer", time.Now())
 	Req := &cachepb.Request{Group: g.name, Key: key}
-	Resp, err := peer.Get(Req)
+	Resp, err := peer.Get(ctx, Req)
+	if status.Code(err) == codes.NotFound || errors.Is(err, ErrNotFound) {
+		g.stats.peerLoads.Add(1)
+		return ByteView{}, ErrNotFound
+	}
 	if err != nil {
+		g.stats.peerErrors.Add(1)
 		return ByteView{}, err
 	}
-	return ByteView{b: CloneBytes(Resp.GetValue())}, nil
+	g.stats.peerLoads.Add(1)
+	return ByteView{b: CloneBytes(Resp.GetValue()), stale: Resp.GetStale()}, nil
 }
 
 // 使用回调函数从本地数据源获取key对应的value值并加载到缓存
-func (g *Group) GetLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+func (g *Group) GetLocally(ctx context.Context, key string) (ByteView, error) {
+	defer observeLoad(g.name, "local", time.Now())
+	ctx, span := g.startSpan(ctx, "Group.GetLocally", attribute.String("key", key))
+	defer span.End()
+	//所属节点过载时溢出到本节点的请求,写入与删除不会到达本节点,加载的值不放入主缓存
+	overflow := g.overflowed(ctx, key)
+	//启用租约时其他调用方正在回填则等待其结果
+	var token uint64
+	if g.leases != nil && !overflow {
+		var filled <-chan struct{}
+		if token, filled = g.acquireLease(key); token == 0 {
+			return g.awaitFill(ctx, key, filled)
+		}
+	}
+	start := time.Now()
+	bytes, err := g.cgetter.GetContext(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		g.stats.localLoads.Add(1)
+		if !overflow {
+			g.fill(key, token, func() { g.populateNegative(key) })
+		}
+		return ByteView{}, ErrNotFound
+	}
 	if err != nil {
+		g.releaseLease(key, token)
+		span.RecordError(err)
+		g.stats.loadErrors.Add(1)
 		return ByteView{}, err
 	}
-	value := ByteView{b: CloneBytes(bytes)}
-	g.PopulateCache(key, value)
+	g.stats.localLoads.Add(1)
+	value := ByteView{b: CloneBytes(bytes), delta: time.Since(start)}
+	if overflow {
+		g.populateOverflow(key, value)
+	} else {
+		g.fill(key, token, func() { g.PopulateCache(key, value) })
+	}
 	return value, nil
 }
 
-// 将key-value加载到缓存
+// 请求是否是所属节点过载时从其他节点溢出到本节点的,本节点既不是key的所属节点也不是副本节点
+func (g *Group) overflowed(ctx context.Context, key string) bool {
+	if g.peers == nil || !isPeerRequest(ctx) {
+		return false
+	}
+	op, ok := g.peers.(OwnerPicker)
+	if !ok {
+		return false
+	}
+	if _, ok := op.PickOwner(key); !ok {
+		return false
+	}
+	_, self := g.pickReplicas(key)
+	return !self
+}
+
+// 溢出到本节点的值只在启用热点缓存时放入热点缓存,写入与删除时随热点缓存的失效广播一起失效
+func (g *Group) populateOverflow(key string, value ByteView) {
+	if g.hotCache.CacheBytes <= 0 {
+		return
+	}
+	g.hotCache.AddWithTTL(key, value, g.ttl)
+}
+
+// 依次查询主缓存与热点缓存
+func (g *Group) lookup(key string) (ByteView, bool) {
+	if value, ok := g.mainCache.Get(key); ok {
+		return value, true
+	}
+	if g.hotCache.CacheBytes <= 0 {
+		return ByteView{}, false
+	}
+	return g.hotCache.Get(key)
+}
+
+// key是否在不存在的key的缓存中
+func (g *Group) isNegative(key string) bool {
+	if g.negCache.CacheBytes <= 0 {
+		return false
+	}
+	_, ok := g.negCache.Get(key)
+	return ok
+}
+
+// 记录数据源中不存在的key
+func (g *Group) populateNegative(key string) {
+	if g.negCache.CacheBytes <= 0 || g.negTTL <= 0 {
+		return
+	}
+	g.negCache.AddWithTTL(key, ByteView{}, g.negTTL)
+}
+
+// 通过singleflight加载,等待其他请求结果的调用计为被合并的加载
+func (g *Group) do(key string, fn func() (any, error)) (any, error) {
+	executed := false
+	value, err := g.loader.Do(key, func() (any, error) {
+		executed = true
+		return fn()
+	})
+	if !executed {
+		g.stats.loadsDeduped.Add(1)
+	}
+	return value, err
+}
+
+// 从远端节点获取的值按概率或热度放入热点缓存,旧值不放入
+func (g *Group) populateHot(key string, value ByteView) {
+	if g.hotCache.CacheBytes <= 0 || value.stale {
+		return
+	}
+	if g.hotSketch != nil {
+		g.hotMutex.Lock()
+		g.hotSketch.Increment(key)
+		hot := g.hotSketch.Estimate(key) >= g.hotLimit
+		g.hotMutex.Unlock()
+		if !hot {
+			return
+		}
+	} else if rand.IntN(g.hotRatio) != 0 {
+		return
+	}
+	g.hotCache.AddWithTTL(key, value, g.ttl)
+}
+
+// 缓存的统计信息
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.Stats()
+	case HotCache:
+		return g.hotCache.Stats()
+	case NegativeCache:
+		return g.negCache.Stats()
+	case GraceCache:
+		return g.grace.Stats()
+	}
+	return CacheStats{}
+}
+
+// 将key-value加载到缓存,使用组默认过期时间
+// 启用了后台刷新时值在过期时间后软过期,在额外的stale时间后才从缓存中删除;未设置过期时间时永不过期
 func (g *Group) PopulateCache(key string, value ByteView) {
-	g.mainCache.Add(key, value)
+	value.stale = false
+	if g.ttl <= 0 {
+		g.mainCache.Add(key, value)
+		return
+	}
+	value.expire = time.Now().Add(g.ttl)
+	g.mainCache.AddWithTTL(key, value, g.ttl+g.stale)
+}
+
+// 缓存命中后检查是否需要后台刷新,返回值是否已软过期
+// 软过期的值总是刷新,未软过期时按XFetch算法: now - delta*beta*ln(rand) >= expire 时提前刷新
+func (g *Group) maybeRefresh(key string, value ByteView) (stale bool) {
+	if value.expire.IsZero() || (g.stale <= 0 && g.beta <= 0) {
+		return false
+	}
+	now := time.Now()
+	if now.After(value.expire) {
+		g.stats.staleHits.Add(1)
+		g.refresh(key)
+		return true
+	}
+	if g.beta > 0 && value.delta > 0 {
+		//1-rand在(0,1]内,ln不会为-Inf;在浮点数中比较,避免early过大时转换为time.Duration溢出
+		early := -float64(value.delta) * g.beta * math.Log(1-rand.Float64())
+		if early >= float64(value.expire.Sub(now)) {
+			g.refresh(key)
+		}
+	}
+	return false
+}
+
+// 主缓存中过期或被淘汰的值移入宽限区,保留到过期后maxStale
+func (g *Group) retain(key string, value ByteView, reason eviction.Reason) {
+	if reason == eviction.Removed {
+		return
+	}
+	ttl := g.maxStale
+	if !value.expire.IsZero() {
+		ttl = time.Until(value.expire.Add(g.stale + g.maxStale))
+	}
+	if ttl > 0 {
+		g.grace.AddWithTTL(key, value, ttl)
+	}
+}
+
+// 加载失败时从宽限区返回旧值,key不存在或请求已取消时不返回
+func (g *Group) serveStale(ctx context.Context, key string, err error) (ByteView, bool) {
+	if g.grace.CacheBytes <= 0 || errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+		return ByteView{}, false
+	}
+	value, ok := g.grace.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	g.stats.staleServes.Add(1)
+	g.logger.Warn("failed to load, serve stale value", "key", key, "err", err)
+	value.stale = true
+	return value, true
+}
+
+// 在后台从数据源重新加载key,同一key同时只有一个刷新协程,并与前台加载共享singleflight
+func (g *Group) refresh(key string) {
+	if _, loaded := g.refreshes.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	g.stats.refreshes.Add(1)
+	go func() {
+		defer g.refreshes.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		value, err := g.do(key, func() (any, error) {
+			return g.GetLocally(ctx, key)
+		})
+		switch {
+		case errors.Is(err, ErrNotFound):
+			g.mainCache.Remove(key)
+		case err != nil:
+			g.logger.Warn("failed to refresh, keep serving stale value", "key", key, "err", err)
+		case g.push:
+			g.pushToReplicas(key, value.(ByteView))
+		}
+	}()
+}
+
+// 写入key-value,key属于远端节点时转发给该节点,并删除本地可能存在的副本
+// 启用write-through或write-behind时同时写入数据源
+func (g *Group) Set(key string, value []byte) error {
+	return g.SetContext(context.Background(), key, value)
+}
+
+// 启用副本时同时写入所有副本节点,本节点不是副本时删除本地副本
+func (g *Group) SetContext(ctx context.Context, key string, value []byte) error {
+	if key == "" {
+		return status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	if err := g.persist(ctx, key, value); err != nil {
+		return err
+	}
+	if replicas, self := g.pickReplicas(key); len(replicas) > 0 || self {
+		if self {
+			g.SetLocally(key, value)
+		} else {
+			g.RemoveLocally(key)
+		}
+		var errs []error
+		for _, peer := range replicas {
+			if _, err := peer.Put(ctx, &cachepb.PutRequest{Group: g.name, Key: key, Value: value}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(append(errs, g.invalidateHot(ctx, key, replicas...))...)
+	}
+	if peer, ok := g.pickOwner(ctx, key); ok {
+		g.RemoveLocally(key)
+		if _, err := peer.Put(ctx, &cachepb.PutRequest{Group: g.name, Key: key, Value: value}); err != nil {
+			return err
+		}
+		return g.invalidateHot(ctx, key, peer)
+	}
+	if err := g.SetLocally(key, value); err != nil {
+		return err
+	}
+	return g.invalidateHot(ctx, key)
+}
+
+// 删除key,key属于远端节点时转发给该节点,并删除本地可能存在的副本
+// 启用write-through或write-behind且数据源实现了Deleter时同时从数据源删除
+func (g *Group) Delete(key string) error {
+	return g.DeleteContext(context.Background(), key)
+}
+
+// 启用副本时同时删除所有副本节点上的key
+func (g *Group) DeleteContext(ctx context.Context, key string) error {
+	if key == "" {
+		return status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	if err := g.unpersist(ctx, key); err != nil {
+		return err
+	}
+	g.RemoveLocally(key)
+	if replicas, _ := g.pickReplicas(key); len(replicas) > 0 {
+		var errs []error
+		for _, peer := range replicas {
+			if _, err := peer.Delete(ctx, &cachepb.Request{Group: g.name, Key: key}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(append(errs, g.invalidateHot(ctx, key, replicas...))...)
+	}
+	if peer, ok := g.pickOwner(ctx, key); ok {
+		if _, err := peer.Delete(ctx, &cachepb.Request{Group: g.name, Key: key}); err != nil {
+			return err
+		}
+		return g.invalidateHot(ctx, key, peer)
+	}
+	return g.invalidateHot(ctx, key)
+}
+
+// 使集群中所有节点上key的副本失效
+func (g *Group) Invalidate(key string) error {
+	return g.InvalidateContext(context.Background(), key)
+}
+
+func (g *Group) InvalidateContext(ctx context.Context, key string) error {
+	if key == "" {
+		return status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	g.RemoveLocally(key)
+	if g.peers == nil {
+		return nil
+	}
+	var errs []error
+	for _, peer := range g.peers.AllPeers() {
+		if _, err := peer.Invalidate(ctx, &cachepb.Request{Group: g.name, Key: key}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 启用热点缓存时其他节点的热点缓存可能保存着key的旧值,写入或删除后使除owners外所有远端节点上key的副本失效
+func (g *Group) invalidateHot(ctx context.Context, key string, owners ...PeerGetter) error {
+	if g.hotCache.CacheBytes <= 0 || g.peers == nil {
+		return nil
+	}
+	var errs []error
+	for _, peer := range g.peers.AllPeers() {
+		if slices.Contains(owners, peer) {
+			continue
+		}
+		if _, err := peer.Invalidate(ctx, &cachepb.Request{Group: g.name, Key: key}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 将key-value写入本节点缓存
+// 启用租约时作废key的租约,持有者随后的回填被拒绝
+func (g *Group) SetLocally(key string, value []byte) error {
+	if key == "" {
+		return status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	if g.leases != nil {
+		g.leases.void(key)
+	}
+	g.store(key, value)
+	return nil
+}
+
+// 将key-value写入主缓存,并删除热点缓存、不存在的key的缓存与宽限区中的旧值
+func (g *Group) store(key string, value []byte) {
+	g.PopulateCache(key, ByteView{b: CloneBytes(value)})
+	g.hotCache.Remove(key)
+	g.negCache.Remove(key)
+	g.grace.Remove(key)
+}
+
+// 删除本节点所有缓存与宽限区中的key,启用租约时作废key的租约
+func (g *Group) RemoveLocally(key string) {
+	if g.leases != nil {
+		g.leases.void(key)
+	}
+	g.mainCache.Remove(key)
+	g.hotCache.Remove(key)
+	g.negCache.Remove(key)
+	g.grace.Remove(key)
+}
+
+// 选择key所属的远端节点,key属于本节点或请求来自远端节点时返回false
+func (g *Group) pickPeer(ctx context.Context, key string) (PeerGetter, bool) {
+	if g.peers == nil || isPeerRequest(ctx) {
+		return nil, false
+	}
+	return g.peers.PickPeer(key)
+}
+
+// 选择key的所属节点,用于写入、删除与租约,key属于本节点或请求来自远端节点时返回false
+func (g *Group) pickOwner(ctx context.Context, key string) (PeerGetter, bool) {
+	if g.peers == nil || isPeerRequest(ctx) {
+		return nil, false
+	}
+	if op, ok := g.peers.(OwnerPicker); ok {
+		return op.PickOwner(key)
+	}
+	return g.peers.PickPeer(key)
+}
+
+// 选择key的副本节点,未启用副本或PeerPicker不支持副本时返回空
+func (g *Group) pickReplicas(key string) (peers []PeerGetter, self bool) {
+	if g.replicas <= 1 {
+		return nil, false
+	}
+	rp, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return nil, false
+	}
+	return rp.PickReplicas(key, g.replicas)
+}
+
+type peerRequestKey struct{}
+
+// 标记来自远端节点的请求,此类请求只在本节点处理,避免节点视图不一致或所属节点故障时请求在节点间循环转发
+func withPeerRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, peerRequestKey{}, true)
+}
+
+func isPeerRequest(ctx context.Context) bool {
+	v, _ := ctx.Value(peerRequestKey{}).(bool)
+	return v
 }
@@ -1,9 +1,25 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var db = map[string]string{
@@ -40,3 +56,1105 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestTTL(t *testing.T) {
+	loadCounts := 0
+	group := NewGroup("ttl", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loadCounts++
+			return []byte(key), nil
+		}), WithTTL(20*time.Millisecond))
+	defer group.Shutdown(context.Background())
+
+	if _, err := group.Get("jack"); err != nil || loadCounts != 1 {
+		t.Fatal("failed to load jack")
+	}
+	if _, err := group.Get("jack"); err != nil || loadCounts != 1 {
+		t.Fatal("cache jack miss before expiration")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := group.Get("jack"); err != nil || loadCounts != 2 {
+		t.Fatal("jack should be reloaded after expiration")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	for _, policy := range []EvictionPolicy{LRU, LFU, ARC, TwoQueue, TinyLFU} {
+		loadCounts := 0
+		group := NewGroup(fmt.Sprintf("policy-%d", policy), 2<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				loadCounts++
+				return []byte(key), nil
+			}), WithPolicy(policy))
+		for range 2 {
+			if view, err := group.Get("jack"); err != nil || view.String() != "jack" || loadCounts != 1 {
+				t.Fatalf("policy %d: failed to get jack", policy)
+			}
+		}
+	}
+}
+
+// 将请求转发给另一个进程内缓存组的模拟远端节点
+type fakePeer struct {
+	owner *Group
+	calls []string
+	down  bool //模拟节点故障,Get返回Unavailable
+}
+
+func (p *fakePeer) Get(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	p.calls = append(p.calls, "Get")
+	if p.down {
+		return nil, status.Error(codes.Unavailable, "peer down")
+	}
+	view, err := p.owner.GetContext(withPeerRequest(ctx), Req.GetKey())
+	return &cachepb.Response{Value: view.ByteSlice()}, err
+}
+func (p *fakePeer) Put(ctx context.Context, Req *cachepb.PutRequest) (*cachepb.Response, error) {
+	p.calls = append(p.calls, "Put")
+	return &cachepb.Response{}, p.owner.SetLocally(Req.GetKey(), Req.GetValue())
+}
+func (p *fakePeer) Delete(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	p.calls = append(p.calls, "Delete")
+	p.owner.RemoveLocally(Req.GetKey())
+	return &cachepb.Response{}, nil
+}
+func (p *fakePeer) Invalidate(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	p.calls = append(p.calls, "Invalidate")
+	p.owner.RemoveLocally(Req.GetKey())
+	return &cachepb.Response{}, nil
+}
+func (p *fakePeer) Lease(ctx context.Context, Req *cachepb.Request) (*cachepb.LeaseResponse, error) {
+	p.calls = append(p.calls, "Lease")
+	view, token, err := p.owner.LeaseLocally(ctx, Req.GetKey())
+	return &cachepb.LeaseResponse{Value: view.ByteSlice(), Token: token}, err
+}
+func (p *fakePeer) Fill(ctx context.Context, Req *cachepb.FillRequest) (*cachepb.Response, error) {
+	p.calls = append(p.calls, "Fill")
+	return &cachepb.Response{}, p.owner.FillLocally(Req.GetKey(), Req.GetValue(), Req.GetToken())
+}
+
+func (p *fakePeer) GetBatch(ctx context.Context, Req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+	p.calls = append(p.calls, "GetBatch")
+	Resp := &cachepb.BatchResponse{}
+	values, errs := p.owner.GetManyContext(withPeerRequest(ctx), Req.GetKeys())
+	for i, key := range Req.GetKeys() {
+		r := &cachepb.Result{Key: key, Value: values[i].ByteSlice()}
+		if errs[i] != nil {
+			r.Error = errs[i].Error()
+		}
+		Resp.Results = append(Resp.Results, r)
+	}
+	return Resp, nil
+}
+
+// remote中的key属于远端节点peer,其余key属于本节点
+type fakePicker struct {
+	peer   *fakePeer
+	remote map[string]bool
+}
+
+func (p *fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	if p.remote[key] {
+		return p.peer, true
+	}
+	return nil, false
+}
+func (p *fakePicker) AllPeers() []PeerGetter {
+	return []PeerGetter{p.peer}
+}
+
+// 在fakePicker的基础上固定返回replicas作为所有key的副本
+type fakeReplicaPicker struct {
+	fakePicker
+	replicas []PeerGetter
+	self     bool
+}
+
+func (p *fakeReplicaPicker) PickReplicas(key string, n int) ([]PeerGetter, bool) {
+	return p.replicas, p.self
+}
+
+func TestSetDelete(t *testing.T) {
+	source := map[string]string{"jack": "256", "tom": "34385"}
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := source[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s not exist", key)
+	})
+	owner := NewGroup("set-owner", 2<<10, getter)
+	local := NewGroup("set-local", 2<<10, getter)
+	peer := &fakePeer{owner: owner}
+	local.RegisterPeers(&fakePicker{peer: peer, remote: map[string]bool{"tom": true}})
+
+	//本节点的key直接写入本地缓存
+	if err := local.Set("jack", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := local.Get("jack"); err != nil || view.String() != "1" {
+		t.Fatalf("set jack locally failed")
+	}
+	//远端节点的key转发给所属节点,并删除本地副本
+	local.SetLocally("tom", []byte("stale"))
+	if err := local.Set("tom", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if local.mainCache.Contains("tom") {
+		t.Fatalf("local copy of tom should be purged")
+	}
+	if view, ok := owner.mainCache.Get("tom"); !ok || view.String() != "2" {
+		t.Fatalf("set tom on owner failed")
+	}
+	if err := local.Delete("tom"); err != nil || owner.mainCache.Contains("tom") {
+		t.Fatalf("delete tom on owner failed")
+	}
+	if err := local.Delete("jack"); err != nil || local.mainCache.Contains("jack") {
+		t.Fatalf("delete jack locally failed")
+	}
+	//Invalidate广播到所有远端节点
+	local.SetLocally("jack", []byte("1"))
+	owner.SetLocally("jack", []byte("1"))
+	if err := local.Invalidate("jack"); err != nil {
+		t.Fatal(err)
+	}
+	if local.mainCache.Contains("jack") || owner.mainCache.Contains("jack") {
+		t.Fatalf("invalidate jack failed")
+	}
+	if expect := []string{"Put", "Delete", "Invalidate"}; !reflect.DeepEqual(peer.calls, expect) {
+		t.Fatalf("peer calls should be %v, got %v", expect, peer.calls)
+	}
+}
+
+// 记录批量加载次数的数据源
+type batchSource struct {
+	data  map[string]string
+	calls int
+}
+
+func (s *batchSource) Get(key string) ([]byte, error) {
+	values, errs := s.GetMany(context.Background(), []string{key})
+	return values[0], errs[0]
+}
+func (s *batchSource) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	s.calls++
+	values, errs := make([][]byte, len(keys)), make([]error, len(keys))
+	for i, key := range keys {
+		if v, ok := s.data[key]; ok {
+			values[i] = []byte(v)
+		} else {
+			errs[i] = fmt.Errorf("%s not exist", key)
+		}
+	}
+	return values, errs
+}
+
+func TestGetMany(t *testing.T) {
+	data := map[string]string{"jack": "256", "tom": "34385", "lucy": "125", "david": "7"}
+	ownerSource, localSource := &batchSource{data: data}, &batchSource{data: data}
+	owner := NewGroup("many-owner", 2<<10, ownerSource)
+	local := NewGroup("many-local", 2<<10, localSource)
+	peer := &fakePeer{owner: owner}
+	local.RegisterPeers(&fakePicker{peer: peer, remote: map[string]bool{"tom": true, "lucy": true, "unknown": true}})
+
+	keys := []string{"jack", "tom", "lucy", "david", "unknown", "jack", ""}
+	values, errs := local.GetMany(keys)
+	for i, key := range keys[:4] {
+		if errs[i] != nil || values[i].String() != data[key] {
+			t.Fatalf("GetMany %s failed: %v", key, errs[i])
+		}
+	}
+	if errs[4] == nil || errs[6] == nil {
+		t.Fatalf("GetMany unknown and empty key should fail")
+	}
+	if errs[5] != nil || values[5].String() != "256" {
+		t.Fatalf("GetMany duplicated jack failed")
+	}
+	if !reflect.DeepEqual(peer.calls, []string{"GetBatch"}) || ownerSource.calls != 1 || localSource.calls != 1 {
+		t.Fatalf("expect one batch per node, got peer calls %v, owner loads %d, local loads %d",
+			peer.calls, ownerSource.calls, localSource.calls)
+	}
+	//再次查询本节点的key命中缓存
+	if _, errs := local.GetMany([]string{"jack", "david"}); errs[0] != nil || errs[1] != nil || localSource.calls != 1 {
+		t.Fatalf("GetMany should hit local cache")
+	}
+}
+
+func TestContext(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	group := NewGroup("context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			select {
+			case <-ctx.Done():
+				cancelled <- struct{}{}
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+				return []byte(key), nil
+			}
+		}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := group.GetContext(ctx, "jack"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Fatalf("cancellation should reach the getter")
+	}
+
+	//不支持context的Getter在ctx结束后不再被调用
+	calls := 0
+	plain := NewGroup("context-plain", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		calls++
+		return []byte(key), nil
+	}))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := plain.GetContext(ctx, "jack"); !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Fatalf("expect canceled without calling getter, got %v", err)
+	}
+	if view, err := plain.Get("jack"); err != nil || view.String() != "jack" {
+		t.Fatalf("GetterFunc should still work")
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	var loads atomic.Int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		return []byte(key), nil
+	})
+	//primary为所属节点,replica为副本节点,local不保存副本
+	primary := NewGroup("replica-primary", 2<<10, getter, WithReplicas(2), WithReplicaPush())
+	replica := NewGroup("replica-replica", 2<<10, getter, WithReplicas(2))
+	local := NewGroup("replica-local", 2<<10, getter, WithReplicas(2))
+	toPrimary := &fakePeer{owner: primary}
+	toReplica := &fakePeer{owner: replica}
+	keys := map[string]bool{"jack": true, "tom": true, "lucy": true}
+	primary.RegisterPeers(&fakeReplicaPicker{replicas: []PeerGetter{toReplica}, self: true})
+	replica.RegisterPeers(&fakeReplicaPicker{fakePicker: fakePicker{peer: toPrimary, remote: keys}, replicas: []PeerGetter{toPrimary}, self: true})
+	local.RegisterPeers(&fakeReplicaPicker{fakePicker: fakePicker{peer: toPrimary, remote: keys}, replicas: []PeerGetter{toPrimary, toReplica}})
+
+	//所属节点从数据源加载后推送到副本
+	if view, err := local.Get("jack"); err != nil || view.String() != "jack" || loads.Load() != 1 {
+		t.Fatalf("get jack from primary failed: %v", err)
+	}
+	for i := 0; !replica.mainCache.Contains("jack"); i++ {
+		if i == 100 {
+			t.Fatalf("jack should be pushed to replica")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	//所属节点故障时从副本读取,不访问数据源
+	toPrimary.down = true
+	if view, err := local.Get("jack"); err != nil || view.String() != "jack" || loads.Load() != 1 {
+		t.Fatalf("get jack from replica failed: %v", err)
+	}
+	//副本未命中时由副本加载,不再转发给所属节点
+	calls := len(toPrimary.calls)
+	if view, err := local.Get("tom"); err != nil || view.String() != "tom" || loads.Load() != 2 {
+		t.Fatalf("get tom from replica failed: %v", err)
+	}
+	if len(toPrimary.calls) != calls+1 {
+		t.Fatalf("replica should not forward peer request, calls %v", toPrimary.calls)
+	}
+	toPrimary.down = false
+
+	//写入与删除作用于所有副本
+	if err := local.Set("lucy", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*Group{primary, replica} {
+		if view, ok := g.mainCache.Get("lucy"); !ok || view.String() != "1" {
+			t.Fatalf("set lucy on %s failed", g.name)
+		}
+	}
+	if local.mainCache.Contains("lucy") {
+		t.Fatalf("local should not keep a copy of lucy")
+	}
+	if err := local.Delete("lucy"); err != nil {
+		t.Fatal(err)
+	}
+	if primary.mainCache.Contains("lucy") || replica.mainCache.Contains("lucy") {
+		t.Fatalf("delete lucy on replicas failed")
+	}
+}
+
+func TestHotCache(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	owner := NewGroup("hot-owner", 2<<10, getter)
+	toOwner := &fakePeer{owner: owner}
+	g := NewGroup("hot-local", 2<<10, getter, WithHotCache(1<<10), WithHotKeyThreshold(3))
+	g.RegisterPeers(&fakePicker{peer: toOwner, remote: map[string]bool{"jack": true}})
+
+	//前两次访问频率未达到阈值,每次都转发给所属节点
+	for i := 1; i <= 3; i++ {
+		if view, err := g.Get("jack"); err != nil || view.String() != "jack" {
+			t.Fatalf("get jack failed: %v", err)
+		}
+		if len(toOwner.calls) != i {
+			t.Fatalf("get %d should be forwarded, calls = %v", i, toOwner.calls)
+		}
+	}
+	//第三次后放入热点缓存,不再转发
+	for range 5 {
+		if view, err := g.Get("jack"); err != nil || view.String() != "jack" {
+			t.Fatalf("get jack from hot cache failed: %v", err)
+		}
+	}
+	if len(toOwner.calls) != 3 {
+		t.Fatalf("hot key should be served locally, calls = %v", toOwner.calls)
+	}
+	if g.mainCache.Contains("jack") {
+		t.Fatalf("peer-owned key should not be in main cache")
+	}
+	if stats := g.CacheStats(HotCache); stats.Items != 1 || stats.Hits != 5 {
+		t.Fatalf("hot cache stats = %+v", stats)
+	}
+	if stats := g.CacheStats(MainCache); stats.Items != 0 || stats.Hits != 0 {
+		t.Fatalf("main cache stats = %+v", stats)
+	}
+
+	//失效时删除热点缓存中的副本
+	if err := g.Invalidate("jack"); err != nil {
+		t.Fatalf("invalidate jack failed: %v", err)
+	}
+	if g.hotCache.Contains("jack") {
+		t.Fatalf("invalidate should remove hot copy")
+	}
+}
+
+// 在fakePicker的基础上还有不拥有任何key的其他节点
+type fakeClusterPicker struct {
+	fakePicker
+	others []PeerGetter
+}
+
+func (p *fakeClusterPicker) AllPeers() []PeerGetter {
+	return append([]PeerGetter{p.peer}, p.others...)
+}
+
+func TestHotCacheWrite(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	owner := NewGroup("hot-write-owner", 2<<10, getter)
+	toOwner := &fakePeer{owner: owner}
+	remote := map[string]bool{"jack": true}
+	other := NewGroup("hot-write-other", 2<<10, getter, WithHotCache(1<<10), WithHotKeyThreshold(1))
+	other.RegisterPeers(&fakePicker{peer: toOwner, remote: remote})
+	toOther := &fakePeer{owner: other}
+	g := NewGroup("hot-write", 2<<10, getter, WithHotCache(1<<10), WithHotKeyThreshold(1))
+	g.RegisterPeers(&fakeClusterPicker{fakePicker: fakePicker{peer: toOwner, remote: remote}, others: []PeerGetter{toOther}})
+
+	//写入后其他节点热点缓存中的旧值失效
+	if view, _ := other.Get("jack"); view.String() != "jack" || !other.hotCache.Contains("jack") {
+		t.Fatalf("jack should be in the hot cache of other node")
+	}
+	if err := g.Set("jack", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := other.Get("jack"); view.String() != "1" {
+		t.Fatalf("other node should see the new value, got %q", view.String())
+	}
+	if slices.Contains(toOwner.calls, "Invalidate") {
+		t.Fatalf("owner should not be invalidated after put, calls = %v", toOwner.calls)
+	}
+	//删除后其他节点热点缓存中的旧值失效
+	if err := g.Delete("jack"); err != nil {
+		t.Fatal(err)
+	}
+	if other.hotCache.Contains("jack") {
+		t.Fatalf("delete should remove the hot copy on other node")
+	}
+}
+
+func TestStats(t *testing.T) {
+	release := make(chan struct{})
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if key == "slow" {
+			<-release
+		}
+		if key == "unknown" {
+			return nil, errors.New("not found")
+		}
+		return []byte(key), nil
+	})
+	owner := NewGroup("stats-owner", 2<<10, getter)
+	g := NewGroup("stats-local", 2<<10, getter)
+	g.RegisterPeers(&fakePicker{peer: &fakePeer{owner: owner}, remote: map[string]bool{"tom": true}})
+
+	g.Get("jack")
+	g.Get("jack")
+	g.Get("tom")
+	g.Get("unknown")
+	//并发的相同请求只加载一次,其余被合并
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Get("slow")
+		}()
+	}
+	for g.stats.misses.Load() < 6 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	want := Stats{Gets: 7, Hits: 1, Misses: 6, PeerLoads: 1, LocalLoads: 2, LoadErrors: 1, LoadsDeduped: 2, Items: 2}
+	want.Bytes = int64(len("jack")*2 + len("slow")*2)
+	if stats := g.Stats(); stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	owner := NewGroup("log-owner", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	down := &fakePeer{owner: owner, down: true}
+	g := NewGroup("log-local", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithLogger(logger), WithLogSampling(2))
+	g.RegisterPeers(&fakePicker{peer: down, remote: map[string]bool{"tom": true}})
+
+	//命中与未命中等热点路径日志共5条,每2条记录一条
+	for range 4 {
+		g.Get("jack")
+	}
+	g.Get("tom")
+	var debugs, warns int
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["group"] != "log-local" {
+			t.Fatalf("log entry without group: %s", line)
+		}
+		switch entry["msg"] {
+		case "cache hit", "cache miss":
+			debugs++
+		case "failed to get from peer, load locally":
+			warns++
+			if entry["level"] != "WARN" || entry["key"] != "tom" || entry["peer"] == "" {
+				t.Fatalf("peer failure entry = %s", line)
+			}
+		}
+	}
+	if debugs != 3 || warns != 1 {
+		t.Fatalf("debugs = %d, warns = %d, log:\n%s", debugs, warns, buf.String())
+	}
+
+	//DiscardLogger不输出任何日志
+	buf.Reset()
+	quiet := NewGroup("log-quiet", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithLogger(DiscardLogger))
+	quiet.Get("jack")
+	if buf.Len() != 0 {
+		t.Fatalf("discard logger should not write")
+	}
+}
+
+func BenchmarkGroupGet(b *testing.B) {
+	g := NewGroup("bench", 2<<20, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithLogger(DiscardLogger))
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			g.Get(keys[i%len(keys)])
+		}
+	})
+}
+
+func TestNegativeCache(t *testing.T) {
+	var loads atomic.Int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		if key == "missing" || key == "gone" {
+			return nil, fmt.Errorf("select %s : %w", key, ErrNotFound)
+		}
+		return nil, errors.New("connection refused")
+	})
+	g := NewGroup("negative", 2<<10, getter, WithNegativeCache(50*time.Millisecond, 1<<10))
+	defer g.Shutdown(context.Background())
+
+	//不存在的key只访问一次数据源,其余错误不缓存
+	for range 3 {
+		if _, err := g.Get("missing"); err != ErrNotFound {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+		if _, err := g.Get("broken"); err == nil || errors.Is(err, ErrNotFound) {
+			t.Fatalf("transport failure should not be not found: %v", err)
+		}
+	}
+	if loads.Load() != 4 {
+		t.Fatalf("missing should be loaded once, broken every time, loads = %d", loads.Load())
+	}
+	if stats := g.Stats(); stats.NegativeHits != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats := g.CacheStats(NegativeCache); stats.Items != 1 || stats.Bytes != int64(len("missing")) {
+		t.Fatalf("negative cache stats = %+v", stats)
+	}
+	if _, errs := g.GetMany([]string{"missing"}); errs[0] != ErrNotFound || loads.Load() != 4 {
+		t.Fatalf("get many should hit the negative cache: %v", errs[0])
+	}
+
+	//过期后重新访问数据源
+	time.Sleep(60 * time.Millisecond)
+	if _, err := g.Get("missing"); err != ErrNotFound || loads.Load() != 5 {
+		t.Fatalf("tombstone should expire, loads = %d", loads.Load())
+	}
+	//写入后不再返回不存在
+	g.Set("missing", []byte("found"))
+	if view, err := g.Get("missing"); err != nil || view.String() != "found" {
+		t.Fatalf("set should replace the tombstone: %v", err)
+	}
+
+	//远端节点返回不存在时不从本地数据源加载
+	local := NewGroup("negative-local", 2<<10, getter)
+	local.RegisterPeers(&fakePicker{peer: &fakePeer{owner: g}, remote: map[string]bool{"gone": true}})
+	if _, err := local.Get("gone"); err != ErrNotFound || loads.Load() != 6 {
+		t.Fatalf("expect ErrNotFound from peer without local load, got %v, loads = %d", err, loads.Load())
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var loads atomic.Int32
+	block := make(chan struct{})
+	g := NewGroup("swr", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if n := loads.Add(1); n > 1 {
+			<-block
+		}
+		return []byte(strconv.Itoa(int(loads.Load()))), nil
+	}), WithTTL(50*time.Millisecond), WithStaleWhileRevalidate(time.Second))
+	defer g.Shutdown(context.Background())
+
+	if view, err := g.Get("jack"); err != nil || view.String() != "1" {
+		t.Fatalf("get jack failed: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	//软过期后立即返回旧值,多次查询只触发一次后台刷新
+	for range 5 {
+		if view, err := g.Get("jack"); err != nil || view.String() != "1" || !view.Stale() {
+			t.Fatalf("stale value should be returned immediately: %v, %v", view, err)
+		}
+	}
+	close(block)
+	for i := 0; ; i++ {
+		if view, _ := g.Get("jack"); view.String() == "2" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("value should be refreshed in background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := g.Stats(); loads.Load() != 2 || stats.Refreshes != 1 || stats.StaleHits < 5 {
+		t.Fatalf("loads = %d, stats = %+v", loads.Load(), stats)
+	}
+	//未设置过期时间时值永不过期
+	forever := NewGroup("swr-no-ttl", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithStaleWhileRevalidate(10*time.Millisecond))
+	forever.Get("jack")
+	time.Sleep(30 * time.Millisecond)
+	forever.Get("jack")
+	if stats := forever.Stats(); stats.LocalLoads != 1 || stats.StaleHits != 0 {
+		t.Fatalf("value without ttl should never expire, stats = %+v", stats)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+	g := NewGroup("xfetch", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		time.Sleep(time.Millisecond)
+		return []byte(key), nil
+	}), WithTTL(time.Hour), WithRefreshAhead(1e9))
+	defer g.Shutdown(context.Background())
+
+	//beta极大时命中即提前刷新,值未软过期,不返回旧值
+	g.Get("jack")
+	if view, err := g.Get("jack"); err != nil || view.String() != "jack" {
+		t.Fatalf("get jack failed: %v", err)
+	}
+	for i := 0; loads.Load() < 2; i++ {
+		if i == 100 {
+			t.Fatalf("hot key should be refreshed ahead of expiry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := g.Stats(); stats.StaleHits != 0 || stats.Refreshes < 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	var fail atomic.Bool
+	g := NewGroup("stale-if-error", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if fail.Load() {
+			return nil, fmt.Errorf("backend down")
+		}
+		return []byte(key), nil
+	}), WithTTL(20*time.Millisecond), WithStaleIfError(100*time.Millisecond, 1<<10), WithLogger(DiscardLogger))
+	defer g.Shutdown(context.Background())
+
+	g.Get("jack")
+	g.Get("tom")
+	fail.Store(true)
+	time.Sleep(30 * time.Millisecond)
+	//数据源故障时返回宽限区中的旧值
+	if view, err := g.Get("jack"); err != nil || view.String() != "jack" || !view.Stale() {
+		t.Fatalf("stale value should be served: %v, %v", view, err)
+	}
+	if values, errs := g.GetMany([]string{"tom", "sam"}); errs[0] != nil || !values[0].Stale() || errs[1] == nil {
+		t.Fatalf("values = %v, errs = %v", values, errs)
+	}
+	if stats := g.Stats(); stats.StaleServes != 2 || stats.LoadErrors != 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	//显式删除的值不保留
+	g.RemoveLocally("jack")
+	if _, err := g.Get("jack"); err == nil {
+		t.Fatalf("removed value should not be served")
+	}
+	//超过最大过期时间后返回错误
+	time.Sleep(100 * time.Millisecond)
+	if _, err := g.Get("tom"); err == nil {
+		t.Fatalf("value older than max staleness should not be served")
+	}
+	//数据源恢复后返回新值
+	fail.Store(false)
+	if view, err := g.Get("tom"); err != nil || view.Stale() {
+		t.Fatalf("fresh value should be loaded: %v, %v", view, err)
+	}
+}
+
+func TestStaleIfErrorHot(t *testing.T) {
+	var fail atomic.Bool
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if fail.Load() {
+			return nil, fmt.Errorf("backend down")
+		}
+		return []byte(key), nil
+	})
+	toOwner := &fakePeer{owner: NewGroup("stale-hot-owner", 2<<10, getter)}
+	g := NewGroup("stale-hot", 2<<10, getter, WithTTL(20*time.Millisecond), WithHotCache(1<<10), WithHotKeyThreshold(1),
+		WithStaleIfError(time.Second, 1<<10), WithLogger(DiscardLogger))
+	defer g.Shutdown(context.Background())
+	g.RegisterPeers(&fakePicker{peer: toOwner, remote: map[string]bool{"jack": true}})
+
+	if _, err := g.Get("jack"); err != nil || !g.hotCache.Contains("jack") {
+		t.Fatalf("jack should be in the hot cache: %v", err)
+	}
+	//热点缓存中的值过期后,所属节点与数据源均故障时返回宽限区中的旧值
+	time.Sleep(30 * time.Millisecond)
+	toOwner.down = true
+	fail.Store(true)
+	if view, err := g.Get("jack"); err != nil || view.String() != "jack" || !view.Stale() {
+		t.Fatalf("stale hot value should be served: %v, %v", view, err)
+	}
+}
+
+// 内存数据源,用于测试写入数据源
+type memStore struct {
+	mutex sync.Mutex
+	data  map[string]string
+	sets  int  //Set调用次数
+	fail  bool //为true时写入失败
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string)}
+}
+
+func (s *memStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if v, ok := s.data[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memStore) Set(ctx context.Context, key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("store down")
+	}
+	s.sets++
+	s.data[key] = string(value)
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("store down")
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memStore) get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *memStore) setFail(fail bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = fail
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := newMemStore()
+	g := NewGroup("write-through", 2<<10, store, WithWriteThrough())
+
+	if err := g.Set("jack", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("jack"); v != "1" {
+		t.Fatalf("value should be written to the store, got %q", v)
+	}
+	if view, err := g.Get("jack"); err != nil || view.String() != "1" {
+		t.Fatalf("get jack failed: %v", err)
+	}
+	//数据源写入失败时缓存不变
+	store.setFail(true)
+	if err := g.Set("jack", []byte("2")); err == nil {
+		t.Fatalf("set should fail when the store fails")
+	}
+	if view, _ := g.Get("jack"); view.String() != "1" {
+		t.Fatalf("cache should be unchanged after a failed write, got %q", view.String())
+	}
+	store.setFail(false)
+	if err := g.Delete("jack"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.get("jack"); ok {
+		t.Fatalf("key should be deleted from the store")
+	}
+	if _, err := g.Get("jack"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound, got %v", err)
+	}
+	if stats := g.Stats(); stats.Writes != 2 || stats.WriteErrors != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	store := newMemStore()
+	g := NewGroup("write-behind", 2<<10, store, WithWriteBehind(time.Hour, 1), WithLogger(DiscardLogger))
+	defer g.Shutdown(context.Background())
+
+	g.Set("jack", []byte("1"))
+	g.Set("jack", []byte("2"))
+	g.Set("tom", []byte("1"))
+	g.Delete("tom")
+	if _, ok := store.get("jack"); ok {
+		t.Fatalf("write should not reach the store before flushing")
+	}
+	if stats := g.Stats(); stats.Pending != 2 || stats.Coalesced != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	//缓存被淘汰后仍能读到未刷新的写入
+	g.RemoveLocally("jack")
+	if view, err := g.Get("jack"); err != nil || view.String() != "2" {
+		t.Fatalf("pending write should be visible: %v, %v", view, err)
+	}
+	if values, errs := g.GetMany([]string{"tom"}); !errors.Is(errs[0], ErrNotFound) {
+		t.Fatalf("pending delete should be visible: %v, %v", values, errs)
+	}
+	if err := g.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("jack"); v != "2" || store.sets != 1 {
+		t.Fatalf("writes should be coalesced, store = %v, sets = %d", store.data, store.sets)
+	}
+	//写入失败的key保留到重试次数用尽
+	store.setFail(true)
+	g.Set("sam", []byte("1"))
+	if err := g.Flush(context.Background()); err == nil || g.Stats().Pending != 1 {
+		t.Fatalf("failed write should be kept for retry: %v", err)
+	}
+	g.Flush(context.Background())
+	if stats := g.Stats(); stats.Pending != 0 || stats.WriteErrors != 2 {
+		t.Fatalf("write should be dropped after retries, stats = %+v", stats)
+	}
+	//关闭时刷新剩余的写入
+	store.setFail(false)
+	g.Set("lucy", []byte("1"))
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("lucy"); v != "1" {
+		t.Fatalf("pending writes should be flushed on shutdown")
+	}
+}
+
+// 只支持写入不支持删除的数据源
+type setOnlyStore struct {
+	store *memStore
+}
+
+func (s setOnlyStore) Get(key string) ([]byte, error) { return s.store.Get(key) }
+func (s setOnlyStore) Set(ctx context.Context, key string, value []byte) error {
+	return s.store.Set(ctx, key, value)
+}
+
+func TestWriteBehindNoDeleter(t *testing.T) {
+	store := newMemStore()
+	g := NewGroup("write-behind-no-deleter", 2<<10, setOnlyStore{store}, WithWriteBehind(time.Hour, 1))
+	defer g.Shutdown(context.Background())
+
+	//数据源不支持删除时,删除丢弃未刷新的写入
+	g.Set("jack", []byte("1"))
+	g.Delete("jack")
+	if view, err := g.Get("jack"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted key should not be served from pending writes: %v, %v", view, err)
+	}
+	if err := g.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.get("jack"); ok {
+		t.Fatalf("deleted key should not be flushed, got %q", v)
+	}
+}
+
+func TestWriteBehindBackground(t *testing.T) {
+	store := newMemStore()
+	g := NewGroup("write-behind-bg", 2<<10, store, WithWriteBehind(10*time.Millisecond, 3))
+	defer g.Shutdown(context.Background())
+
+	g.Set("jack", []byte("1"))
+	for i := 0; ; i++ {
+		if v, _ := store.get("jack"); v == "1" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("write should be flushed in background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteBehindInterval(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("non-positive interval should panic")
+		}
+	}()
+	WithWriteBehind(0, 3)
+}
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+	var loads atomic.Int32
+	entered, block := make(chan struct{}), make(chan struct{})
+	g := NewGroup("lease", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		if key == "lucy" && loads.Load() == 1 {
+			close(entered)
+			<-block
+			return []byte("old"), nil
+		}
+		return []byte(key), nil
+	}), WithLeases(time.Second, 50*time.Millisecond), WithLogger(DiscardLogger))
+
+	//未命中时发放租约,其他调用方等待超时后得到重试提示
+	_, token, err := g.Lease(ctx, "jack")
+	if err != nil || token == 0 {
+		t.Fatalf("miss should hand out a lease: %v", err)
+	}
+	if _, _, err := g.Lease(ctx, "jack"); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("expect ErrLeaseHeld, got %v", err)
+	}
+	//等待中的调用方在回填后得到回填的值
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		g.Fill(ctx, "jack", []byte("1"), token)
+	}()
+	if view, token, err := g.Lease(ctx, "jack"); err != nil || token != 0 || view.String() != "1" {
+		t.Fatalf("waiter should get the filled value: %v, %d, %v", view, token, err)
+	}
+	//回填前失效的租约被作废,回填被拒绝
+	_, token, _ = g.Lease(ctx, "tom")
+	g.Invalidate("tom")
+	if err := g.Fill(ctx, "tom", []byte("stale"), token); !errors.Is(err, ErrLeaseInvalid) {
+		t.Fatalf("expect ErrLeaseInvalid, got %v", err)
+	}
+	if _, token, _ := g.Lease(ctx, "tom"); token == 0 {
+		t.Fatalf("rejected fill should not be cached")
+	}
+	//从数据源加载期间key被写入时不缓存加载到的旧值
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Get("lucy")
+	}()
+	<-entered
+	g.Set("lucy", []byte("new"))
+	close(block)
+	<-done
+	if view, _ := g.Get("lucy"); view.String() != "new" {
+		t.Fatalf("stale fill should not overwrite a newer write, got %q", view.String())
+	}
+	if stats := g.Stats(); stats.Leases != 4 || stats.LeaseRejects != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	plain := NewGroup("no-lease", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	if _, _, err := plain.Lease(ctx, "jack"); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expect Unimplemented, got %v", err)
+	}
+}
+
+func TestLeaseSweep(t *testing.T) {
+	g := NewGroup("lease-sweep", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithLeases(time.Millisecond, time.Millisecond))
+	for i := range 1000 {
+		if _, token, _ := g.Lease(context.Background(), strconv.Itoa(i)); token == 0 {
+			t.Fatalf("miss should hand out a lease for %d", i)
+		}
+	}
+	//从不回填的租约过期后在之后获取租约时被清理
+	time.Sleep(5 * time.Millisecond)
+	g.Lease(context.Background(), "jack")
+	g.leases.mutex.Lock()
+	defer g.leases.mutex.Unlock()
+	if n := len(g.leases.leases); n != 1 {
+		t.Fatalf("expired leases should be removed, %d left", n)
+	}
+}
+
+func TestGetManyLeases(t *testing.T) {
+	ctx := context.Background()
+	data := map[string]string{"jack": "256", "tom": "34385", "lucy": "125", "david": "7"}
+	g := NewGroup("many-lease", 2<<10, &batchSource{data: data}, WithLeases(time.Second, 50*time.Millisecond), WithLogger(DiscardLogger))
+	for _, key := range []string{"jack", "tom", "lucy"} {
+		if _, token, _ := g.Lease(ctx, key); token == 0 {
+			t.Fatalf("miss should hand out a lease for %s", key)
+		}
+	}
+	//所有被持有的key同时等待,总等待时间不随key数增长
+	start := time.Now()
+	values, errs := g.GetMany([]string{"jack", "tom", "lucy", "david"})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("held keys should share one deadline, waited %v", elapsed)
+	}
+	for i := range 3 {
+		if !errors.Is(errs[i], ErrLeaseHeld) {
+			t.Fatalf("expect ErrLeaseHeld, got %v", errs[i])
+		}
+	}
+	if errs[3] != nil || values[3].String() != "7" {
+		t.Fatalf("david = %v, %v", values[3], errs[3])
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("non-positive lease ttl should panic")
+		}
+	}()
+	WithLeases(0, time.Millisecond)
+}
+
+// PickPeer按负载选择的节点与所属节点不同
+type fakeOwnerPicker struct {
+	fakePicker
+	owner *fakePeer
+}
+
+func (p *fakeOwnerPicker) PickOwner(key string) (PeerGetter, bool) {
+	if p.remote[key] {
+		return p.owner, true
+	}
+	return nil, false
+}
+
+func TestPickOwner(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	owner := NewGroup("pick-owner", 2<<10, getter, WithLeases(time.Second, 10*time.Millisecond))
+	spill := &fakePeer{owner: NewGroup("pick-owner-spill", 2<<10, getter)}
+	toOwner := &fakePeer{owner: owner}
+	g := NewGroup("pick-owner-local", 2<<10, getter)
+	g.RegisterPeers(&fakeOwnerPicker{fakePicker: fakePicker{peer: spill, remote: map[string]bool{"jack": true}}, owner: toOwner})
+
+	ctx := context.Background()
+	if err := g.Set("jack", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Delete("jack"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := g.Lease(ctx, "jack"); err != nil {
+		t.Fatal(err)
+	}
+	if len(spill.calls) != 0 || !slices.Equal(toOwner.calls, []string{"Put", "Delete", "Lease"}) {
+		t.Fatalf("mutations should go to the owner, spill calls = %v, owner calls = %v", spill.calls, toOwner.calls)
+	}
+	if owner.Stats().Leases != 1 {
+		t.Fatalf("lease should be handed out by the owner")
+	}
+}
+
+func TestOverflowLoad(t *testing.T) {
+	var loads atomic.Int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		return []byte(key), nil
+	})
+	toOwner := &fakePeer{owner: NewGroup("overflow-owner", 2<<10, getter)}
+	remote := map[string]bool{"jack": true, "tom": true}
+	//本节点不是jack与tom的所属节点,请求因所属节点过载溢出到本节点
+	g := NewGroup("overflow", 2<<10, getter)
+	g.RegisterPeers(&fakeOwnerPicker{fakePicker: fakePicker{peer: toOwner, remote: remote}, owner: toOwner})
+	ctx := withPeerRequest(context.Background())
+	for range 2 {
+		if view, err := g.GetContext(ctx, "jack"); err != nil || view.String() != "jack" {
+			t.Fatalf("get jack failed: %v", err)
+		}
+		g.GetManyContext(ctx, []string{"tom"})
+	}
+	if g.mainCache.Contains("jack") || g.mainCache.Contains("tom") || loads.Load() != 4 {
+		t.Fatalf("overflow loads should not be cached, loads = %d", loads.Load())
+	}
+	//所属节点收到的请求照常放入主缓存
+	if _, err := g.GetContext(ctx, "lucy"); err != nil || !g.mainCache.Contains("lucy") {
+		t.Fatalf("owned key should be cached: %v", err)
+	}
+
+	//启用热点缓存时放入热点缓存,写入时随失效广播一起失效
+	hot := NewGroup("overflow-hot", 2<<10, getter, WithHotCache(1<<10))
+	hot.RegisterPeers(&fakeOwnerPicker{fakePicker: fakePicker{peer: toOwner, remote: remote}, owner: toOwner})
+	hot.GetContext(ctx, "jack")
+	if hot.mainCache.Contains("jack") || !hot.hotCache.Contains("jack") {
+		t.Fatalf("overflow load should be kept in the hot cache")
+	}
+}
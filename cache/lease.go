@@ -0,0 +1,252 @@
+// 租约: 防止失效与回填并发时缓存旧值,并限制同一key的并发回填
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// 其他调用方持有key的租约且在等待时间内未回填,稍后重试即可
+	ErrLeaseHeld = errors.New("lease held by another caller, retry later")
+	// 租约已过期,或在回填前key被写入或失效,回填的值可能已过时而被拒绝
+	ErrLeaseInvalid = errors.New("lease is invalid or expired")
+)
+
+// 启用租约: 所属节点未命中时发放租约,只有持有者的回填被缓存,回填前key被写入或失效时租约作废
+// 租约在ttl(必须大于0)后过期,其他调用方最多等待wait,持有者仍未回填时返回ErrLeaseHeld
+func WithLeases(ttl, wait time.Duration) GroupOption {
+	if ttl <= 0 {
+		panic("lease ttl must be positive!")
+	}
+	return func(g *Group) {
+		g.leases = &leaseTable{ttl: ttl, wait: wait, leases: make(map[string]*lease)}
+	}
+}
+
+// 一个未回填的租约
+type lease struct {
+	token  uint64
+	expire time.Time
+	filled chan struct{} //回填、作废或过期后关闭,唤醒等待的调用方
+}
+
+// 本节点所属key的租约
+type leaseTable struct {
+	ttl    time.Duration
+	wait   time.Duration
+	mutex  sync.Mutex
+	leases map[string]*lease
+	swept  time.Time //上次清理过期租约的时间
+}
+
+// 获取key的租约,token为0表示租约已被其他调用方持有,可等待filled关闭
+func (lt *leaseTable) acquire(key string) (token uint64, filled <-chan struct{}) {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	now := time.Now()
+	lt.sweep(now)
+	if l, ok := lt.leases[key]; ok {
+		if now.Before(l.expire) {
+			return 0, l.filled
+		}
+		close(l.filled)
+	}
+	l := &lease{token: rand.Uint64() | 1, expire: now.Add(lt.ttl), filled: make(chan struct{})}
+	lt.leases[key] = l
+	return l.token, nil
+}
+
+// 删除过期的租约,避免获取后从不回填的租约一直占用内存,每个ttl周期最多清理一次
+func (lt *leaseTable) sweep(now time.Time) {
+	if now.Sub(lt.swept) < lt.ttl {
+		return
+	}
+	lt.swept = now
+	for key, l := range lt.leases {
+		if !now.Before(l.expire) {
+			delete(lt.leases, key)
+			close(l.filled)
+		}
+	}
+}
+
+// 租约有效时在持有锁的情况下执行回填并结束租约,返回租约是否有效
+// 作废在同一把锁下进行,因此回填要么在作废前完成并随后被失效删除,要么被拒绝
+func (lt *leaseTable) fill(key string, token uint64, fn func()) bool {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	l, ok := lt.leases[key]
+	if !ok || l.token != token || !time.Now().Before(l.expire) {
+		return false
+	}
+	fn()
+	delete(lt.leases, key)
+	close(l.filled)
+	return true
+}
+
+// 持有者放弃租约(加载失败),其他调用方可以重新获取
+func (lt *leaseTable) release(key string, token uint64) {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	if l, ok := lt.leases[key]; ok && l.token == token {
+		delete(lt.leases, key)
+		close(l.filled)
+	}
+}
+
+// 作废key的租约,持有者随后的回填会被拒绝
+func (lt *leaseTable) void(key string) {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	if l, ok := lt.leases[key]; ok {
+		delete(lt.leases, key)
+		close(l.filled)
+	}
+}
+
+// 查询key,命中时token为0;未命中时返回非0的租约,调用方从数据源加载后以该租约调用Fill
+// 其他调用方持有租约时等待其回填,超时返回ErrLeaseHeld;key属于远端节点时转发给该节点
+func (g *Group) Lease(ctx context.Context, key string) (value ByteView, token uint64, err error) {
+	if key == "" {
+		return ByteView{}, 0, status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	if peer, ok := g.pickOwner(ctx, key); ok {
+		if lg, ok := peer.(LeaseGetter); ok {
+			Resp, err := lg.Lease(ctx, &cachepb.Request{Group: g.name, Key: key})
+			if err != nil {
+				return ByteView{}, 0, leaseError(err)
+			}
+			return ByteView{b: CloneBytes(Resp.GetValue()), stale: Resp.GetStale()}, Resp.GetToken(), nil
+		}
+	}
+	return g.LeaseLocally(ctx, key)
+}
+
+// 以Lease返回的租约回填key,租约已失效时返回ErrLeaseInvalid且值不被缓存
+func (g *Group) Fill(ctx context.Context, key string, value []byte, token uint64) error {
+	if key == "" {
+		return status.Errorf(codes.InvalidArgument, "key is required")
+	}
+	if peer, ok := g.pickOwner(ctx, key); ok {
+		if lg, ok := peer.(LeaseGetter); ok {
+			_, err := lg.Fill(ctx, &cachepb.FillRequest{Group: g.name, Key: key, Value: value, Token: token})
+			return leaseError(err)
+		}
+	}
+	return g.FillLocally(key, value, token)
+}
+
+// 在本节点查询key并在未命中时发放租约
+func (g *Group) LeaseLocally(ctx context.Context, key string) (ByteView, uint64, error) {
+	if g.leases == nil {
+		return ByteView{}, 0, status.Errorf(codes.Unimplemented, "leases are not enabled for group %s", g.name)
+	}
+	if value, ok := g.lookup(key); ok {
+		value.stale = g.maybeRefresh(key, value)
+		return value, 0, nil
+	}
+	if g.isNegative(key) {
+		return ByteView{}, 0, ErrNotFound
+	}
+	token, filled := g.acquireLease(key)
+	if token != 0 {
+		return ByteView{}, token, nil
+	}
+	value, err := g.awaitFill(ctx, key, filled)
+	return value, 0, err
+}
+
+// 以租约回填本节点缓存
+func (g *Group) FillLocally(key string, value []byte, token uint64) error {
+	if g.leases == nil {
+		return status.Errorf(codes.Unimplemented, "leases are not enabled for group %s", g.name)
+	}
+	if !g.fill(key, token, func() { g.store(key, value) }) {
+		return ErrLeaseInvalid
+	}
+	return nil
+}
+
+// 租约有效时执行回填,未启用租约时直接回填
+func (g *Group) fill(key string, token uint64, fn func()) bool {
+	if g.leases == nil {
+		fn()
+		return true
+	}
+	if !g.leases.fill(key, token, fn) {
+		g.stats.leaseRejects.Add(1)
+		g.logger.Debug("fill rejected, lease voided or expired", "key", key)
+		return false
+	}
+	return true
+}
+
+// 获取key的租约并计数
+func (g *Group) acquireLease(key string) (token uint64, filled <-chan struct{}) {
+	if token, filled = g.leases.acquire(key); token != 0 {
+		g.stats.leases.Add(1)
+	}
+	return token, filled
+}
+
+// 加载失败时放弃租约
+func (g *Group) releaseLease(key string, token uint64) {
+	if g.leases != nil {
+		g.leases.release(key, token)
+	}
+}
+
+// 等待其他调用方回填key,回填后返回缓存中的值,等待超时或租约被作废时返回ErrLeaseHeld
+func (g *Group) awaitFill(ctx context.Context, key string, filled <-chan struct{}) (ByteView, error) {
+	expired, stop := g.leaseDeadline()
+	defer stop()
+	return g.awaitFillUntil(ctx, key, filled, expired)
+}
+
+// 等待的截止时间,wait后关闭expired,多个key的等待可以共用同一截止时间
+func (g *Group) leaseDeadline() (expired <-chan struct{}, stop func() bool) {
+	done := make(chan struct{})
+	timer := time.AfterFunc(g.leases.wait, func() { close(done) })
+	return done, timer.Stop
+}
+
+// 等待其他调用方回填key直到expired关闭
+func (g *Group) awaitFillUntil(ctx context.Context, key string, filled, expired <-chan struct{}) (ByteView, error) {
+	select {
+	case <-filled:
+		if value, ok := g.lookup(key); ok {
+			return value, nil
+		}
+		if g.isNegative(key) {
+			return ByteView{}, ErrNotFound
+		}
+	case <-expired:
+	case <-ctx.Done():
+		return ByteView{}, ctx.Err()
+	}
+	return ByteView{}, ErrLeaseHeld
+}
+
+// 将远端节点返回的状态码转换为租约相关的错误
+func leaseError(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.Aborted:
+		return ErrLeaseHeld
+	case codes.FailedPrecondition:
+		return ErrLeaseInvalid
+	}
+	return err
+}
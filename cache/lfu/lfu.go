@@ -0,0 +1,215 @@
+package lfu
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+// LFU cache,淘汰访问频率最低的结点,频率相同时淘汰最久未访问的结点
+// 所有操作均为O(1)
+type Cache struct {
+	maxBytes  int64
+	nowBytes  int64
+	freqs     *list.List //按频率升序排列的频率桶
+	cache     map[string]*list.Element
+	OnEvicted eviction.EvictedFunc
+}
+
+// 构造函数
+func New(maxBytes int64, OnEvicted eviction.EvictedFunc) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		nowBytes:  0,
+		freqs:     list.New(),
+		cache:     make(map[string]*list.Element),
+		OnEvicted: OnEvicted,
+	}
+}
+
+// 频率桶,items中的结点按访问先后排列
+type bucket struct {
+	freq  int
+	items *list.List
+}
+
+// 频率桶内存储的结点类型
+type entry struct {
+	key    string
+	value  eviction.Value
+	expire time.Time     //过期时间,零值表示永不过期
+	owner  *list.Element //所属频率桶
+}
+
+// 从缓存中查询key并增加其访问频率,已过期的key视为未命中并被惰性删除
+func (c *Cache) Get(key string) (value eviction.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if eviction.IsExpired(kv.expire, time.Now()) {
+			c.removeElement(ele, eviction.Expired)
+			return nil, false
+		}
+		c.increment(ele)
+		return kv.value, true
+	}
+	return nil, false
+}
+
+// 将结点移动到频率+1的桶中
+func (c *Cache) increment(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	cur := kv.owner
+	freq := cur.Value.(*bucket).freq + 1
+	next := cur.Next()
+	if next == nil || next.Value.(*bucket).freq != freq {
+		next = c.freqs.InsertAfter(&bucket{freq: freq, items: list.New()}, cur)
+	}
+	cur.Value.(*bucket).items.Remove(ele)
+	kv.owner = next
+	c.cache[kv.key] = next.Value.(*bucket).items.PushBack(kv)
+	if cur.Value.(*bucket).items.Len() == 0 {
+		c.freqs.Remove(cur)
+	}
+}
+
+// 查询key但不增加其访问频率,已过期的key视为未命中
+func (c *Cache) Peek(key string) (value eviction.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !eviction.IsExpired(kv.expire, time.Now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// 判断key是否在缓存中,不增加其访问频率
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// 删除key,返回key是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele, eviction.Removed)
+		return true
+	}
+	return false
+}
+
+// 缓存淘汰
+func (c *Cache) RemoveOldest() {
+	if front := c.freqs.Front(); front != nil {
+		c.removeElement(front.Value.(*bucket).items.Front(), eviction.Capacity)
+	}
+}
+
+// 清理所有已过期的结点,返回清理数量
+func (c *Cache) RemoveExpired() int {
+	now, cnt := time.Now(), 0
+	for _, ele := range c.cache {
+		if eviction.IsExpired(ele.Value.(*entry).expire, now) {
+			c.removeElement(ele, eviction.Expired)
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// 删除结点并触发回调
+func (c *Cache) removeElement(ele *list.Element, reason eviction.Reason) {
+	kv := ele.Value.(*entry)
+	items := kv.owner.Value.(*bucket).items
+	items.Remove(ele)
+	if items.Len() == 0 {
+		c.freqs.Remove(kv.owner)
+	}
+	delete(c.cache, kv.key)
+	c.nowBytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+// 添加或修改缓存键值对,永不过期
+func (c *Cache) Add(key string, value eviction.Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加或修改缓存键值对,ttl<=0表示永不过期
+// 修改已有结点视为一次访问,新结点的访问频率为1
+func (c *Cache) AddWithTTL(key string, value eviction.Value, ttl time.Duration) {
+	expire := eviction.Deadline(ttl)
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		c.nowBytes += int64(value.Len()) - int64(kv.value.Len())
+		kv.value, kv.expire = value, expire
+		c.increment(ele)
+	} else {
+		front := c.freqs.Front()
+		if front == nil || front.Value.(*bucket).freq != 1 {
+			front = c.freqs.PushFront(&bucket{freq: 1, items: list.New()})
+		}
+		kv := &entry{key: key, value: value, expire: expire, owner: front}
+		c.cache[key] = front.Value.(*bucket).items.PushBack(kv)
+		c.nowBytes += int64(value.Len()) + int64(len(key))
+	}
+	for c.maxBytes != 0 && c.nowBytes > c.maxBytes {
+		c.RemoveOldest()
+	}
+}
+
+// 按淘汰顺序(频率升序,同频率时从最久未访问开始)返回所有未过期的key
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, len(c.cache))
+	c.Range(func(key string, value eviction.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// 按淘汰顺序遍历未过期的结点,fn返回false时停止
+func (c *Cache) Range(fn func(key string, value eviction.Value) bool) {
+	now := time.Now()
+	for b := c.freqs.Front(); b != nil; b = b.Next() {
+		for ele := b.Value.(*bucket).items.Front(); ele != nil; ele = ele.Next() {
+			kv := ele.Value.(*entry)
+			if eviction.IsExpired(kv.expire, now) {
+				continue
+			}
+			if !fn(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// 调整缓存容量,返回因此被淘汰的结点数
+func (c *Cache) Resize(maxBytes int64) int {
+	c.maxBytes = maxBytes
+	cnt := 0
+	for c.maxBytes != 0 && c.nowBytes > c.maxBytes {
+		c.RemoveOldest()
+		cnt++
+	}
+	return cnt
+}
+
+// 清空缓存,对每个结点触发回调
+func (c *Cache) Purge() {
+	for front := c.freqs.Front(); front != nil; front = c.freqs.Front() {
+		c.removeElement(front.Value.(*bucket).items.Front(), eviction.Removed)
+	}
+}
+
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// 缓存已使用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nowBytes
+}
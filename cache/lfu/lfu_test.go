@@ -0,0 +1,126 @@
+package lfu
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+func TestGet(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	if v, ok := lfu.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := lfu.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+func TestRemoveoldest(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "k3"
+	v1, v2, v3 := "value1", "value2", "v3"
+	cap := len(k1 + k2 + v1 + v2)
+	lfu := New(int64(cap), nil)
+	lfu.Add(k1, String(v1))
+	lfu.Add(k2, String(v2))
+	lfu.Get(k1)
+	lfu.Add(k3, String(v3))
+
+	if _, ok := lfu.Get("key2"); ok || lfu.Len() != 2 {
+		t.Fatalf("Removeoldest key2 failed")
+	}
+	if _, ok := lfu.Get("key1"); !ok {
+		t.Fatalf("frequently used key1 should not be evicted")
+	}
+}
+func TestOnEvicted(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value eviction.Value, reason eviction.Reason) {
+		keys = append(keys, key)
+	}
+	lfu := New(int64(10), callback)
+	lfu.Add("key1", String("123456"))
+	lfu.Add("k2", String("k2"))
+	lfu.Add("k3", String("k3"))
+	lfu.Add("k4", String("k4"))
+
+	expect := []string{"key1", "k2"}
+
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
+	}
+}
+func TestFrequency(t *testing.T) {
+	lfu := New(int64(12), nil)
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+	for range 3 {
+		lfu.Get("k1")
+	}
+	lfu.Get("k2")
+	lfu.Add("k4", String("v4"))
+	lfu.Add("k5", String("v5"))
+	for _, key := range []string{"k1", "k2"} {
+		if _, ok := lfu.Get(key); !ok {
+			t.Fatalf("frequently used %s should not be evicted", key)
+		}
+	}
+	if _, ok := lfu.Get("k3"); ok {
+		t.Fatalf("least frequently used k3 should be evicted")
+	}
+}
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]eviction.Reason)
+	lfu := New(int64(0), func(key string, value eviction.Value, reason eviction.Reason) {
+		reasons[key] = reason
+	})
+	lfu.AddWithTTL("key1", String("1234"), 10*time.Millisecond)
+	lfu.AddWithTTL("key2", String("5678"), 10*time.Millisecond)
+	lfu.Add("key3", String("9"))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := lfu.Get("key1"); ok || reasons["key1"] != eviction.Expired {
+		t.Fatalf("lazy expiration of key1 failed")
+	}
+	if n := lfu.RemoveExpired(); n != 1 || lfu.Len() != 1 {
+		t.Fatalf("RemoveExpired should remove 1 key, but %d removed", n)
+	}
+}
+func TestRemove(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	lfu.Add("key2", String("5678"))
+	if !lfu.Remove("key1") || lfu.Contains("key1") || lfu.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if lfu.Remove("key3") {
+		t.Fatalf("Remove nonexistent key3 should return false")
+	}
+	if v, ok := lfu.Peek("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("Peek key2 failed")
+	}
+	if keys := lfu.Keys(); !reflect.DeepEqual(keys, []string{"key2"}) {
+		t.Fatalf("Keys should be [key2], got %v", keys)
+	}
+}
+func TestResize(t *testing.T) {
+	lfu := New(int64(0), nil)
+	for i := range 10 {
+		lfu.Add("k"+strconv.Itoa(i), String("v"))
+	}
+	if n := lfu.Resize(15); n == 0 || lfu.Len() > 5 {
+		t.Fatalf("Resize should evict keys, %d evicted", n)
+	}
+	lfu.Purge()
+	if lfu.Len() != 0 || len(lfu.Keys()) != 0 {
+		t.Fatalf("Purge failed")
+	}
+}
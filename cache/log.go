@@ -0,0 +1,39 @@
+// 结构化日志
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+const defaultLogSampling = 100 //热点路径默认每100条记录一条
+
+// 不输出任何日志的Logger,用于基准测试等需要静默的场景
+var DiscardLogger = slog.New(slog.DiscardHandler)
+
+// 热点路径的日志采样器,每n条只记录一条,n<=1时全部记录
+type logSampler struct {
+	n   int64
+	cnt atomic.Int64
+}
+
+// 以Debug级别记录热点路径日志,未启用Debug级别时不计数
+func (s *logSampler) debug(logger *slog.Logger, msg string, args ...any) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	if s.n > 1 && (s.cnt.Add(1)-1)%s.n != 0 {
+		return
+	}
+	logger.Debug(msg, args...)
+}
+
+// 日志中远端节点的地址,非rpc客户端时返回其类型
+func peerAddr(peer PeerGetter) string {
+	if client, ok := peer.(*CacheClient); ok {
+		return client.BaseURL
+	}
+	return fmt.Sprintf("%T", peer)
+}
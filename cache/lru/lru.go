@@ -2,6 +2,18 @@ package lru
 
 import (
 	"container/list"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+// 淘汰原因
+type EvictReason = eviction.Reason
+
+const (
+	EvictCapacity = eviction.Capacity //容量不足被淘汰
+	EvictExpired  = eviction.Expired  //过期被淘汰
+	EvictRemoved  = eviction.Removed  //被显式删除
 )
 
 // LRU cache
@@ -10,11 +22,11 @@ type Cache struct {
 	nowBytes  int64
 	lst       *list.List
 	cache     map[string]*list.Element
-	OnEvicted func(key string, value Value)
+	OnEvicted func(key string, value Value, reason EvictReason)
 }
 
 // 构造函数
-func New(maxBytes int64, OnEvicted func(string, Value)) *Cache {
+func New(maxBytes int64, OnEvicted func(string, Value, EvictReason)) *Cache {
 	return &Cache{
 		maxBytes:  maxBytes,
 		nowBytes:  0,
@@ -26,48 +38,102 @@ func New(maxBytes int64, OnEvicted func(string, Value)) *Cache {
 
 // 双向链表存储的结点类型
 type entry struct {
-	key   string
-	value Value
+	key    string
+	value  Value
+	expire time.Time //过期时间,零值表示永不过期
 }
 
 // 实现了Value接口的值都可被Cache接受
-type Value interface {
-	Len() int
-}
+type Value = eviction.Value
 
-// 从缓存中查询key
+// 从缓存中查询key,已过期的key视为未命中并被惰性删除
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if ele, ok := c.cache[key]; ok {
-		c.lst.MoveToBack(ele)
 		kv := ele.Value.(*entry) //类型断言,返回(entry,ok),失败则panic
+		if eviction.IsExpired(kv.expire, time.Now()) {
+			c.removeElement(ele, EvictExpired)
+			return nil, false
+		}
+		c.lst.MoveToBack(ele)
 		return kv.value, true
 	}
 	return nil, false
 }
 
+// 查询key但不改变其访问顺序,已过期的key视为未命中
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !eviction.IsExpired(kv.expire, time.Now()) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// 判断key是否在缓存中,不改变其访问顺序
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// 删除key,返回key是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele, EvictRemoved)
+		return true
+	}
+	return false
+}
+
 // 缓存淘汰
 func (c *Cache) RemoveOldest() {
 	ele := c.lst.Front()
 	if ele != nil {
-		c.lst.Remove(ele)
-		kv := ele.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.nowBytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+		c.removeElement(ele, EvictCapacity)
+	}
+}
+
+// 清理所有已过期的结点,返回清理数量
+func (c *Cache) RemoveExpired() int {
+	now, cnt := time.Now(), 0
+	for ele := c.lst.Front(); ele != nil; {
+		next := ele.Next()
+		if eviction.IsExpired(ele.Value.(*entry).expire, now) {
+			c.removeElement(ele, EvictExpired)
+			cnt++
 		}
+		ele = next
+	}
+	return cnt
+}
+
+// 删除结点并触发回调
+func (c *Cache) removeElement(ele *list.Element, reason EvictReason) {
+	c.lst.Remove(ele)
+	kv := ele.Value.(*entry)
+	delete(c.cache, kv.key)
+	c.nowBytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
 	}
 }
 
-// 添加或修改缓存键值对
+// 添加或修改缓存键值对,永不过期
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加或修改缓存键值对,ttl<=0表示永不过期
+func (c *Cache) AddWithTTL(key string, value Value, ttl time.Duration) {
+	expire := eviction.Deadline(ttl)
 	if ele, ok := c.cache[key]; ok {
 		c.lst.MoveToBack(ele)
 		kv := ele.Value.(*entry)
 		c.nowBytes += int64(value.Len()) - int64(kv.value.Len())
-		ele.Value = value
+		kv.value, kv.expire = value, expire
 	} else {
-		ele := c.lst.PushBack(&entry{key, value})
+		ele := c.lst.PushBack(&entry{key, value, expire})
 		c.nowBytes += int64(value.Len()) + int64(len(key))
 		c.cache[key] = ele
 	}
@@ -76,6 +142,62 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+// 按从最久未使用到最近使用的顺序返回所有未过期的key
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.lst.Len())
+	c.Range(func(key string, value Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// 按从最久未使用到最近使用的顺序遍历未过期的结点,fn返回false时停止
+func (c *Cache) Range(fn func(key string, value Value) bool) {
+	now := time.Now()
+	for ele := c.lst.Front(); ele != nil; ele = ele.Next() {
+		kv := ele.Value.(*entry)
+		if eviction.IsExpired(kv.expire, now) {
+			continue
+		}
+		if !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// 调整缓存容量,返回因此被淘汰的结点数
+func (c *Cache) Resize(maxBytes int64) int {
+	c.maxBytes = maxBytes
+	cnt := 0
+	for c.maxBytes != 0 && c.nowBytes > c.maxBytes {
+		c.RemoveOldest()
+		cnt++
+	}
+	return cnt
+}
+
+// 清空缓存,对每个结点触发回调
+func (c *Cache) Purge() {
+	for c.lst.Len() > 0 {
+		c.removeElement(c.lst.Front(), EvictRemoved)
+	}
+}
+
 func (c *Cache) Len() int {
 	return c.lst.Len()
 }
+
+// 缓存已使用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nowBytes
+}
+
+// 返回下一个将被淘汰的结点,不改变其访问顺序
+func (c *Cache) Oldest() (key string, value Value, ok bool) {
+	if ele := c.lst.Front(); ele != nil {
+		kv := ele.Value.(*entry)
+		return kv.key, kv.value, true
+	}
+	return "", nil, false
+}
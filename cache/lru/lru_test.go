@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -35,7 +36,7 @@ func TestRemoveoldest(t *testing.T) {
 }
 func TestOnEvicted(t *testing.T) {
 	keys := make([]string, 0)
-	callback := func(key string, value Value) {
+	callback := func(key string, value Value, reason EvictReason) {
 		keys = append(keys, key)
 	}
 	lru := New(int64(10), callback)
@@ -50,3 +51,108 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := New(int64(0), func(key string, value Value, reason EvictReason) {
+		reasons[key] = reason
+	})
+	lru.AddWithTTL("key1", String("1234"), 10*time.Millisecond)
+	lru.Add("key2", String("5678"))
+	if _, ok := lru.Get("key1"); !ok {
+		t.Fatalf("cache hit key1 before expiration failed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 {
+		t.Fatalf("lazy expiration of key1 failed")
+	}
+	if reason, ok := reasons["key1"]; !ok || reason != EvictExpired {
+		t.Fatalf("Call OnEvicted with expired reason failed")
+	}
+	if _, ok := lru.Get("key2"); !ok {
+		t.Fatalf("key2 without ttl should never expire")
+	}
+}
+func TestRemoveExpired(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.AddWithTTL("key1", String("1"), 10*time.Millisecond)
+	lru.AddWithTTL("key2", String("2"), 10*time.Millisecond)
+	lru.AddWithTTL("key3", String("3"), time.Hour)
+	time.Sleep(20 * time.Millisecond)
+	if n := lru.RemoveExpired(); n != 2 || lru.Len() != 1 {
+		t.Fatalf("RemoveExpired should remove 2 keys, but %d removed", n)
+	}
+}
+func TestRemove(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := New(int64(0), func(key string, value Value, reason EvictReason) {
+		reasons[key] = reason
+	})
+	lru.Add("key1", String("1234"))
+	if !lru.Remove("key1") || lru.Contains("key1") || lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if reasons["key1"] != EvictRemoved {
+		t.Fatalf("Call OnEvicted with removed reason failed")
+	}
+	if lru.Remove("key2") {
+		t.Fatalf("Remove nonexistent key2 should return false")
+	}
+}
+func TestPeek(t *testing.T) {
+	lru := New(int64(10), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	if v, ok := lru.Peek("k1"); !ok || string(v.(String)) != "v1" {
+		t.Fatalf("Peek k1 failed")
+	}
+	//Peek不更新访问顺序,k1仍最先被淘汰
+	lru.Add("k3", String("v3"))
+	if lru.Contains("k1") || !lru.Contains("k2") {
+		t.Fatalf("Peek should not promote k1")
+	}
+}
+func TestKeys(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	lru.Get("k1")
+	lru.AddWithTTL("k4", String("v4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if expect := []string{"k2", "k3", "k1"}; !reflect.DeepEqual(lru.Keys(), expect) {
+		t.Fatalf("Keys should be %v, got %v", expect, lru.Keys())
+	}
+	keys := make([]string, 0)
+	lru.Range(func(key string, value Value) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if expect := []string{"k2", "k3"}; !reflect.DeepEqual(keys, expect) {
+		t.Fatalf("Range should stop at %v, got %v", expect, keys)
+	}
+}
+func TestResize(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	if n := lru.Resize(8); n != 1 || lru.Contains("k1") || lru.Len() != 2 {
+		t.Fatalf("Resize should evict k1, %d evicted", n)
+	}
+	lru.Add("k4", String("v4"))
+	if lru.Contains("k2") || lru.Len() != 2 {
+		t.Fatalf("new capacity should be applied")
+	}
+}
+func TestPurge(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(0), func(key string, value Value, reason EvictReason) {
+		keys = append(keys, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Purge()
+	if lru.Len() != 0 || lru.Bytes() != 0 || !reflect.DeepEqual(keys, []string{"k1", "k2"}) {
+		t.Fatalf("Purge failed")
+	}
+}
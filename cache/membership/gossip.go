@@ -0,0 +1,310 @@
+package membership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var errStopped = errors.New("memberlist stopped")
+
+// 启动后台探测与同步协程,gossip服务需先注册到本节点的rpc服务上
+func (ml *Memberlist) Start() {
+	ml.start.Do(func() {
+		go ml.notifyLoop()
+		go ml.probeLoop()
+		if ml.syncInterval > 0 {
+			go ml.syncLoop()
+		}
+		ml.changed()
+	})
+}
+
+// 停止后台协程并关闭所有连接,不通知其他节点
+func (ml *Memberlist) Stop() {
+	ml.stop.Do(func() {
+		close(ml.done)
+		ml.mutex.Lock()
+		defer ml.mutex.Unlock()
+		for addr := range ml.conns {
+			ml.closeConn(addr)
+		}
+	})
+}
+
+// 通过种子节点加入集群,与种子节点交换全量成员表,任一种子节点成功即返回nil
+func (ml *Memberlist) Join(ctx context.Context, seeds ...string) error {
+	var errs []error
+	for _, seed := range seeds {
+		if seed == ml.self {
+			continue
+		}
+		Resp, err := ml.client(seed).Sync(ctx, &cachepb.GossipMessage{From: ml.self, Members: ml.snapshot()})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("join %s : %w", seed, err))
+			continue
+		}
+		ml.merge(Resp.GetMembers())
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// 主动离开集群: 将自身标记为离开并通知所有存活节点,然后停止
+func (ml *Memberlist) Leave(ctx context.Context) error {
+	ml.mutex.Lock()
+	me := ml.members[ml.self]
+	me.Incarnation++
+	me.State, me.since = Left, time.Now()
+	msg := &cachepb.GossipMessage{From: ml.self, Members: []*cachepb.Member{ml.encode(me)}}
+	peers := ml.others("")
+	ml.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(peers))
+	for i, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = ml.client(peer).Ping(ctx, msg)
+		}()
+	}
+	wg.Wait()
+	ml.Stop()
+	return errors.Join(errs...)
+}
+
+// 除自身和exclude外的存活节点,调用者需持有锁
+func (ml *Memberlist) others(exclude string) []string {
+	peers := make([]string, 0, len(ml.members))
+	for addr, m := range ml.members {
+		if addr != ml.self && addr != exclude && (m.State == Alive || m.State == Suspect) {
+			peers = append(peers, addr)
+		}
+	}
+	return peers
+}
+
+// 获取到addr的gossip客户端,连接在节点故障或离开时关闭
+func (ml *Memberlist) client(addr string) cachepb.GossipClient {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	select {
+	case <-ml.done:
+		return failedClient{errStopped}
+	default:
+	}
+	conn, ok := ml.conns[addr]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(strings.TrimPrefix(addr, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return failedClient{err}
+		}
+		ml.conns[addr] = conn
+	}
+	return cachepb.NewGossipClient(conn)
+}
+
+// 关闭到addr的连接,调用者需持有锁
+func (ml *Memberlist) closeConn(addr string) {
+	if conn, ok := ml.conns[addr]; ok {
+		conn.Close()
+		delete(ml.conns, addr)
+	}
+}
+
+// 无法建立连接时返回的客户端,所有调用均返回建立连接时的错误
+type failedClient struct {
+	err error
+}
+
+func (fc failedClient) Ping(ctx context.Context, in *cachepb.GossipMessage, opts ...grpc.CallOption) (*cachepb.GossipMessage, error) {
+	return nil, fc.err
+}
+
+func (fc failedClient) PingReq(ctx context.Context, in *cachepb.PingRequest, opts ...grpc.CallOption) (*cachepb.GossipMessage, error) {
+	return nil, fc.err
+}
+
+func (fc failedClient) Sync(ctx context.Context, in *cachepb.GossipMessage, opts ...grpc.CallOption) (*cachepb.GossipMessage, error) {
+	return nil, fc.err
+}
+
+// 后台协程,每个周期探测一个节点并检查疑似故障是否超时
+func (ml *Memberlist) probeLoop() {
+	ticker := time.NewTicker(ml.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ml.done:
+			return
+		case <-ticker.C:
+			ml.probe()
+			ml.reap()
+		}
+	}
+}
+
+// 后台协程,定期与随机节点交换全量成员表,修复gossip丢失的变化
+func (ml *Memberlist) syncLoop() {
+	ticker := time.NewTicker(ml.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ml.done:
+			return
+		case <-ticker.C:
+			ml.mutex.Lock()
+			peers := ml.others("")
+			ml.mutex.Unlock()
+			if len(peers) == 0 {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), ml.probeInterval)
+			ml.Join(ctx, peers[rand.IntN(len(peers))])
+			cancel()
+		}
+	}
+}
+
+// 轮流选择下一个探测目标,一轮结束后重新打乱顺序
+func (ml *Memberlist) nextTarget() (string, bool) {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	for len(ml.probeList) > 0 {
+		target := ml.probeList[0]
+		ml.probeList = ml.probeList[1:]
+		if m, ok := ml.members[target]; ok && (m.State == Alive || m.State == Suspect) {
+			return target, true
+		}
+	}
+	ml.probeList = ml.others("")
+	rand.Shuffle(len(ml.probeList), func(i, j int) {
+		ml.probeList[i], ml.probeList[j] = ml.probeList[j], ml.probeList[i]
+	})
+	if len(ml.probeList) == 0 {
+		return "", false
+	}
+	target := ml.probeList[0]
+	ml.probeList = ml.probeList[1:]
+	return target, true
+}
+
+// 探测一个节点: 先直接探测,失败后请k个节点间接探测,均失败则标记为疑似故障
+func (ml *Memberlist) probe() {
+	target, ok := ml.nextTarget()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ml.probeTimeout)
+	err := ml.ping(ctx, target)
+	cancel()
+	if err == nil || ml.indirectPing(target) {
+		return
+	}
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	if m, ok := ml.members[target]; ok && m.State == Alive && ml.apply(target, m.Incarnation, Suspect) {
+		ml.changed()
+	}
+}
+
+// 直接探测,对方的回复中附带其成员变化
+func (ml *Memberlist) ping(ctx context.Context, target string) error {
+	Resp, err := ml.client(target).Ping(ctx, &cachepb.GossipMessage{From: ml.self, Members: ml.piggyback()})
+	if err != nil {
+		return err
+	}
+	ml.merge(Resp.GetMembers())
+	return nil
+}
+
+// 请随机选择的k个节点间接探测target,任一节点探测成功即返回true
+func (ml *Memberlist) indirectPing(target string) bool {
+	ml.mutex.Lock()
+	peers := ml.others(target)
+	ml.mutex.Unlock()
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	peers = peers[:min(len(peers), ml.indirectChecks)]
+	if len(peers) == 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*ml.probeTimeout)
+	defer cancel()
+	acks := make(chan bool, len(peers))
+	for _, peer := range peers {
+		go func() {
+			Resp, err := ml.client(peer).PingReq(ctx, &cachepb.PingRequest{From: ml.self, Target: target, Members: ml.piggyback()})
+			if err == nil {
+				ml.merge(Resp.GetMembers())
+			}
+			acks <- err == nil
+		}()
+	}
+	for range peers {
+		if <-acks {
+			return true
+		}
+	}
+	return false
+}
+
+// 疑似故障超时的节点判定为故障
+func (ml *Memberlist) reap() {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	changed := false
+	for addr, m := range ml.members {
+		if m.State == Suspect && time.Since(m.since) > ml.suspectTimeout {
+			changed = ml.apply(addr, m.Incarnation, Dead) || changed
+		}
+	}
+	if changed {
+		ml.changed()
+	}
+}
+
+// 回复中附带待广播的变化,若发送方在本节点看来已故障或离开,额外附带其记录以便对方反驳
+func (ml *Memberlist) reply(from string) []*cachepb.Member {
+	updates := ml.piggyback()
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	if m, ok := ml.members[from]; ok && m.State != Alive {
+		updates = append(updates, ml.encode(m))
+	}
+	return updates
+}
+
+// 处理直接探测
+func (ml *Memberlist) Ping(ctx context.Context, Req *cachepb.GossipMessage) (*cachepb.GossipMessage, error) {
+	ml.merge(Req.GetMembers())
+	return &cachepb.GossipMessage{From: ml.self, Members: ml.reply(Req.GetFrom())}, nil
+}
+
+// 代替发送方探测目标节点
+func (ml *Memberlist) PingReq(ctx context.Context, Req *cachepb.PingRequest) (*cachepb.GossipMessage, error) {
+	ml.merge(Req.GetMembers())
+	if err := ml.ping(ctx, Req.GetTarget()); err != nil {
+		return &cachepb.GossipMessage{}, status.Errorf(codes.Unavailable, "%s unreachable : %v", Req.GetTarget(), err)
+	}
+	return &cachepb.GossipMessage{From: ml.self, Members: ml.reply(Req.GetFrom())}, nil
+}
+
+// 交换全量成员表,用于节点加入与定期同步
+func (ml *Memberlist) Sync(ctx context.Context, Req *cachepb.GossipMessage) (*cachepb.GossipMessage, error) {
+	ml.merge(Req.GetMembers())
+	return &cachepb.GossipMessage{From: ml.self, Members: ml.snapshot()}, nil
+}
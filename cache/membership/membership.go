@@ -0,0 +1,301 @@
+// SWIM风格的gossip成员管理
+// 节点通过种子节点加入集群,周期性地探测随机选择的节点,直接探测失败时请其他节点间接探测,
+// 仍失败则将其标记为疑似故障,疑似状态超时后判定为故障;成员变化附带在探测消息中以gossip方式传播
+package membership
+
+import (
+	"math/bits"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"google.golang.org/grpc"
+)
+
+const maxPiggyback = 16 //每条消息最多附带的成员变化数
+
+// 成员状态
+type State int32
+
+const (
+	Alive   State = iota //存活
+	Suspect              //疑似故障
+	Dead                 //故障
+	Left                 //主动离开
+)
+
+func (s State) String() string {
+	switch s {
+	case Alive:
+		return "alive"
+	case Suspect:
+		return "suspect"
+	case Dead:
+		return "dead"
+	case Left:
+		return "left"
+	}
+	return "unknown"
+}
+
+// 集群成员,Incarnation由成员自身递增,用于反驳关于自身的过时消息
+type Member struct {
+	Addr        string
+	Incarnation uint64
+	State       State
+	since       time.Time //进入当前状态的时间
+}
+
+// 成员表
+type Memberlist struct {
+	cachepb.UnimplementedGossipServer
+	self           string
+	probeInterval  time.Duration
+	probeTimeout   time.Duration
+	suspectTimeout time.Duration
+	syncInterval   time.Duration
+	indirectChecks int
+	retransmitMult int
+	OnChange       func(members []string) //存活成员变化时的回调,参数为排序后的存活成员地址,含自身
+
+	mutex     sync.Mutex
+	members   map[string]*Member
+	queue     map[string]int //待广播的成员变化及已发送次数
+	probeList []string
+	conns     map[string]*grpc.ClientConn
+	notify    chan struct{}
+	done      chan struct{}
+	start     sync.Once
+	stop      sync.Once
+}
+
+// 成员表配置
+type Option func(*Memberlist)
+
+// 探测周期
+func WithProbeInterval(d time.Duration) Option {
+	return func(ml *Memberlist) {
+		ml.probeInterval = d
+	}
+}
+
+// 单次探测的超时时间
+func WithProbeTimeout(d time.Duration) Option {
+	return func(ml *Memberlist) {
+		ml.probeTimeout = d
+	}
+}
+
+// 疑似故障的节点在该时间内未反驳则判定为故障
+func WithSuspectTimeout(d time.Duration) Option {
+	return func(ml *Memberlist) {
+		ml.suspectTimeout = d
+	}
+}
+
+// 与随机节点全量同步成员表的周期,0表示不同步
+func WithSyncInterval(d time.Duration) Option {
+	return func(ml *Memberlist) {
+		ml.syncInterval = d
+	}
+}
+
+// 直接探测失败时请求间接探测的节点数
+func WithIndirectChecks(k int) Option {
+	return func(ml *Memberlist) {
+		ml.indirectChecks = k
+	}
+}
+
+// 成员变化的重传系数,每条变化最多随消息发送mult*log2(n+1)次
+func WithRetransmitMult(mult int) Option {
+	return func(ml *Memberlist) {
+		ml.retransmitMult = mult
+	}
+}
+
+// 构造函数,self为本节点地址,格式为http://host:port
+func New(self string, opts ...Option) *Memberlist {
+	ml := &Memberlist{
+		self:           self,
+		probeInterval:  time.Second,
+		probeTimeout:   500 * time.Millisecond,
+		suspectTimeout: 5 * time.Second,
+		syncInterval:   30 * time.Second,
+		indirectChecks: 3,
+		retransmitMult: 4,
+		members:        make(map[string]*Member),
+		queue:          make(map[string]int),
+		conns:          make(map[string]*grpc.ClientConn),
+		notify:         make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ml)
+	}
+	ml.members[self] = &Member{Addr: self, State: Alive, since: time.Now()}
+	return ml
+}
+
+// 本节点地址
+func (ml *Memberlist) Self() string {
+	return ml.self
+}
+
+// 返回所有已知成员,按地址排序
+func (ml *Memberlist) Members() []Member {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	members := make([]Member, 0, len(ml.members))
+	for _, m := range ml.members {
+		members = append(members, *m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Addr < members[j].Addr
+	})
+	return members
+}
+
+// 返回存活(含疑似故障)成员的地址,按地址排序
+func (ml *Memberlist) Alive() []string {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	return ml.alive()
+}
+
+func (ml *Memberlist) alive() []string {
+	addrs := make([]string, 0, len(ml.members))
+	for addr, m := range ml.members {
+		if m.State == Alive || m.State == Suspect {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+// 合并收到的成员变化
+func (ml *Memberlist) merge(updates []*cachepb.Member) {
+	ml.mutex.Lock()
+	changed := false
+	for _, u := range updates {
+		if ml.apply(u.GetAddr(), u.GetIncarnation(), State(u.GetState())) {
+			changed = true
+		}
+	}
+	ml.mutex.Unlock()
+	if changed {
+		ml.changed()
+	}
+}
+
+// 应用一条成员变化,返回成员表是否改变,调用者需持有锁
+func (ml *Memberlist) apply(addr string, incarnation uint64, state State) bool {
+	if addr == "" {
+		return false
+	}
+	if addr == ml.self {
+		me := ml.members[ml.self]
+		if me.State == Alive && state != Alive && incarnation >= me.Incarnation {
+			//反驳关于自身的疑似或故障消息
+			me.Incarnation = incarnation + 1
+		}
+		return false
+	}
+	m, ok := ml.members[addr]
+	if !ok {
+		m = &Member{Addr: addr, State: Dead}
+		ml.members[addr] = m
+	} else if !overrides(m, incarnation, state) {
+		return false
+	}
+	m.Incarnation, m.State, m.since = incarnation, state, time.Now()
+	ml.queue[addr] = 0
+	if state == Dead || state == Left {
+		ml.closeConn(addr)
+	}
+	return true
+}
+
+// 判断消息是否比已知状态更新
+// 同一incarnation下 故障/离开 > 疑似 > 存活,更大的incarnation总是更新
+func overrides(m *Member, incarnation uint64, state State) bool {
+	if incarnation != m.Incarnation {
+		return incarnation > m.Incarnation
+	}
+	switch state {
+	case Suspect:
+		return m.State == Alive
+	case Dead, Left:
+		return m.State == Alive || m.State == Suspect
+	}
+	return false
+}
+
+// 将成员变化转换为消息,调用者需持有锁
+func (ml *Memberlist) encode(m *Member) *cachepb.Member {
+	return &cachepb.Member{Addr: m.Addr, Incarnation: m.Incarnation, State: int32(m.State)}
+}
+
+// 选取待广播的成员变化附带在消息中,自身状态总是附带
+// 发送次数达到上限的变化不再广播
+func (ml *Memberlist) piggyback() []*cachepb.Member {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	limit := ml.retransmitMult * bits.Len(uint(len(ml.members)))
+	addrs := make([]string, 0, len(ml.queue))
+	for addr := range ml.queue {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return ml.queue[addrs[i]] < ml.queue[addrs[j]]
+	})
+	updates := []*cachepb.Member{ml.encode(ml.members[ml.self])}
+	for _, addr := range addrs[:min(len(addrs), maxPiggyback)] {
+		updates = append(updates, ml.encode(ml.members[addr]))
+		if ml.queue[addr]++; ml.queue[addr] >= limit {
+			delete(ml.queue, addr)
+		}
+	}
+	return updates
+}
+
+// 全量成员表
+func (ml *Memberlist) snapshot() []*cachepb.Member {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	updates := make([]*cachepb.Member, 0, len(ml.members))
+	for _, m := range ml.members {
+		updates = append(updates, ml.encode(m))
+	}
+	return updates
+}
+
+// 通知后台协程成员表已改变
+func (ml *Memberlist) changed() {
+	select {
+	case ml.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 后台协程,存活成员变化时调用OnChange,保证回调按顺序执行且总能看到最新的成员
+func (ml *Memberlist) notifyLoop() {
+	var last []string
+	for {
+		select {
+		case <-ml.done:
+			return
+		case <-ml.notify:
+			alive := ml.Alive()
+			if !slices.Equal(alive, last) {
+				last = alive
+				if ml.OnChange != nil {
+					ml.OnChange(alive)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package membership
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"google.golang.org/grpc"
+)
+
+// 测试用的较短周期
+var testOptions = []Option{
+	WithProbeInterval(50 * time.Millisecond),
+	WithProbeTimeout(25 * time.Millisecond),
+	WithSuspectTimeout(200 * time.Millisecond),
+	WithSyncInterval(500 * time.Millisecond),
+}
+
+type testNode struct {
+	*Memberlist
+	server *grpc.Server
+	views  chan []string
+}
+
+// 在回环地址的空闲端口上启动一个节点
+func startNode(t *testing.T) *testNode {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &testNode{
+		Memberlist: New("http://"+listener.Addr().String(), testOptions...),
+		server:     grpc.NewServer(),
+		views:      make(chan []string, 64),
+	}
+	node.OnChange = func(members []string) {
+		select {
+		case node.views <- members:
+		default:
+		}
+	}
+	cachepb.RegisterGossipServer(node.server, node.Memberlist)
+	go node.server.Serve(listener)
+	node.Start()
+	t.Cleanup(node.kill)
+	return node
+}
+
+// 模拟节点崩溃,不通知其他节点
+func (node *testNode) kill() {
+	node.Stop()
+	node.server.Stop()
+}
+
+// 等待cond成立,超时则失败
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 启动n个节点并通过第一个节点加入集群,等待所有节点的成员表收敛
+func startCluster(t *testing.T, n int) ([]*testNode, []string) {
+	nodes := make([]*testNode, n)
+	addrs := make([]string, n)
+	for i := range nodes {
+		nodes[i] = startNode(t)
+		addrs[i] = nodes[i].Self()
+	}
+	for _, node := range nodes[1:] {
+		if err := node.Join(context.Background(), nodes[0].Self()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slices.Sort(addrs)
+	waitFor(t, "cluster converged", func() bool {
+		for _, node := range nodes {
+			if !slices.Equal(node.Alive(), addrs) {
+				return false
+			}
+		}
+		return true
+	})
+	return nodes, addrs
+}
+
+func TestJoin(t *testing.T) {
+	nodes, addrs := startCluster(t, 4)
+	for _, node := range nodes {
+		var last []string
+		waitFor(t, "OnChange with all members", func() bool {
+			for {
+				select {
+				case last = <-node.views:
+				default:
+					return slices.Equal(last, addrs)
+				}
+			}
+		})
+	}
+	if err := startNode(t).Join(context.Background(), "http://127.0.0.1:1"); err == nil {
+		t.Fatalf("join unreachable seed should fail")
+	}
+}
+
+func TestFailureDetection(t *testing.T) {
+	nodes, addrs := startCluster(t, 4)
+	dead := nodes[3]
+	dead.kill()
+	alive := slices.DeleteFunc(slices.Clone(addrs), func(addr string) bool {
+		return addr == dead.Self()
+	})
+	waitFor(t, "dead node removed", func() bool {
+		for _, node := range nodes[:3] {
+			if !slices.Equal(node.Alive(), alive) {
+				return false
+			}
+		}
+		return true
+	})
+	for _, m := range nodes[0].Members() {
+		if m.Addr == dead.Self() && m.State != Dead {
+			t.Fatalf("expect %s dead, got %s", m.Addr, m.State)
+		}
+	}
+}
+
+func TestLeave(t *testing.T) {
+	nodes, addrs := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := nodes[2].Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	alive := slices.DeleteFunc(slices.Clone(addrs), func(addr string) bool {
+		return addr == nodes[2].Self()
+	})
+	//离开的消息直接发送给所有节点,无需等待故障检测
+	for _, node := range nodes[:2] {
+		if !slices.Equal(node.Alive(), alive) {
+			t.Fatalf("expect %v after leave, got %v", alive, node.Alive())
+		}
+		for _, m := range node.Members() {
+			if m.Addr == nodes[2].Self() && m.State != Left {
+				t.Fatalf("expect %s left, got %s", m.Addr, m.State)
+			}
+		}
+	}
+}
+
+func TestRefute(t *testing.T) {
+	ml := New("http://localhost:8001")
+	ml.merge([]*cachepb.Member{
+		{Addr: "http://localhost:8001", Incarnation: 0, State: int32(Suspect)},
+		{Addr: "http://localhost:8002", Incarnation: 3, State: int32(Alive)},
+	})
+	me := ml.Members()[0]
+	if me.State != Alive || me.Incarnation != 1 {
+		t.Fatalf("suspicion about self should be refuted, got %+v", me)
+	}
+	if updates := ml.piggyback(); updates[0].GetIncarnation() != 1 {
+		t.Fatalf("refutation should be gossiped, got %v", updates)
+	}
+
+	peer := "http://localhost:8002"
+	for _, c := range []struct {
+		incarnation uint64
+		state       State
+		want        State
+	}{
+		{2, Suspect, Alive}, //过时的消息被忽略
+		{3, Suspect, Suspect},
+		{3, Alive, Suspect}, //同一incarnation下存活不能覆盖疑似
+		{4, Alive, Alive},
+		{4, Dead, Dead},
+		{4, Suspect, Dead},
+		{5, Alive, Alive}, //节点以更大的incarnation重新加入
+	} {
+		ml.merge([]*cachepb.Member{{Addr: peer, Incarnation: c.incarnation, State: int32(c.state)}})
+		if got := ml.Members()[1]; got.State != c.want {
+			t.Fatalf("after %s@%d expect %s, got %s", c.state, c.incarnation, c.want, got.State)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Prometheus指标
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	loadSeconds = metrics.Default.Histogram("cache_load_duration_seconds",
+		"Latency of loading a value on cache miss, by group and source (peer, local, peer_batch, local_batch).", nil, "group", "source")
+	serverHandled = metrics.Default.Counter("cache_grpc_server_handled_total",
+		"Total gRPC calls handled by this node, by method and status code.", "method", "code")
+	serverSeconds = metrics.Default.Histogram("cache_grpc_server_handling_seconds",
+		"Latency of gRPC calls handled by this node.", nil, "method")
+	clientHandled = metrics.Default.Counter("cache_grpc_client_handled_total",
+		"Total gRPC calls made to other nodes, by method and status code.", "method", "code")
+	clientSeconds = metrics.Default.Histogram("cache_grpc_client_handling_seconds",
+		"Latency of gRPC calls made to other nodes.", nil, "method")
+)
+
+// 缓存组的统计信息在采集时从Group.Stats读取
+func init() {
+	counter := func(name, help string, fn func(Stats) int64) {
+		metrics.Default.CounterFunc(name, help, []string{"group"}, groupSamples(fn))
+	}
+	gauge := func(name, help string, fn func(Stats) int64) {
+		metrics.Default.GaugeFunc(name, help, []string{"group"}, groupSamples(fn))
+	}
+	counter("cache_group_gets_total", "Total keys requested from the group.", func(s Stats) int64 { return s.Gets })
+	counter("cache_group_hits_total", "Total requests served from the main, hot or negative cache.", func(s Stats) int64 { return s.Hits })
+	counter("cache_group_negative_hits_total", "Total requests answered from the negative cache of missing keys.", func(s Stats) int64 { return s.NegativeHits })
+	counter("cache_group_stale_hits_total", "Total requests served a soft-expired value while it was refreshed.", func(s Stats) int64 { return s.StaleHits })
+	counter("cache_group_refreshes_total", "Total background refreshes of soft-expired or soon-to-expire values.", func(s Stats) int64 { return s.Refreshes })
+	counter("cache_group_stale_serves_total", "Total expired values served from the grace area because loading failed.", func(s Stats) int64 { return s.StaleServes })
+	counter("cache_group_misses_total", "Total requests that missed the cache.", func(s Stats) int64 { return s.Misses })
+	counter("cache_group_peer_loads_total", "Total values loaded from peers.", func(s Stats) int64 { return s.PeerLoads })
+	counter("cache_group_peer_errors_total", "Total failed loads from peers.", func(s Stats) int64 { return s.PeerErrors })
+	counter("cache_group_local_loads_total", "Total values loaded from the local getter.", func(s Stats) int64 { return s.LocalLoads })
+	counter("cache_group_load_errors_total", "Total failed loads from the local getter.", func(s Stats) int64 { return s.LoadErrors })
+	counter("cache_group_loads_deduped_total", "Total loads merged into a concurrent load of the same key.", func(s Stats) int64 { return s.LoadsDeduped })
+	counter("cache_group_writes_total", "Total writes and deletes persisted to the data source.", func(s Stats) int64 { return s.Writes })
+	counter("cache_group_write_errors_total", "Total failed writes and deletes to the data source.", func(s Stats) int64 { return s.WriteErrors })
+	counter("cache_group_writes_coalesced_total", "Total write-behind writes replaced by a later write of the same key before flushing.", func(s Stats) int64 { return s.Coalesced })
+	gauge("cache_group_writes_pending", "Write-behind writes not yet flushed to the data source.", func(s Stats) int64 { return s.Pending })
+	counter("cache_group_leases_total", "Total leases handed out on misses.", func(s Stats) int64 { return s.Leases })
+	counter("cache_group_lease_rejects_total", "Total fills rejected because the lease was voided or expired.", func(s Stats) int64 { return s.LeaseRejects })
+	counter("cache_group_evictions_total", "Total entries evicted for capacity.", func(s Stats) int64 { return s.Evictions })
+	gauge("cache_group_bytes", "Bytes used by the main, hot and negative cache.", func(s Stats) int64 { return s.Bytes })
+	gauge("cache_group_items", "Entries in the main, hot and negative cache.", func(s Stats) int64 { return s.Items })
+}
+
+// 每个缓存组一个样本
+func groupSamples(fn func(Stats) int64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		mu.RLock()
+		defer mu.RUnlock()
+		samples := make([]metrics.Sample, 0, len(groups))
+		for name, g := range groups {
+			samples = append(samples, metrics.Sample{Values: []string{name}, Value: float64(fn(g.Stats()))})
+		}
+		return samples
+	}
+}
+
+// 记录一次加载的耗时
+func observeLoad(group, source string, start time.Time) {
+	loadSeconds.Observe(time.Since(start).Seconds(), group, source)
+}
+
+// 服务端拦截器,按方法统计调用次数、状态码与耗时
+func serverInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	Resp, err := handler(ctx, req)
+	serverHandled.Inc(info.FullMethod, status.Code(err).String())
+	serverSeconds.Observe(time.Since(start).Seconds(), info.FullMethod)
+	return Resp, err
+}
+
+// 客户端拦截器,按方法统计调用次数、状态码与耗时
+func clientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	clientHandled.Inc(method, status.Code(err).String())
+	clientSeconds.Observe(time.Since(start).Seconds(), method)
+	return err
+}
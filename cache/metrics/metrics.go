@@ -0,0 +1,311 @@
+// Prometheus文本格式的指标
+// 提供计数器、直方图与采集时回调的指标,由Handler以text/plain; version=0.0.4格式输出
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 默认的直方图分桶,单位为秒
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 进程内默认的指标注册表
+var Default = NewRegistry()
+
+// 指标注册表,按注册顺序输出
+type Registry struct {
+	mutex      sync.Mutex
+	collectors map[string]collector
+	names      []string
+}
+
+// 一个指标族
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// 构造函数
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// 注册指标,同名指标已存在时返回已注册的指标,类型不同时panic
+func register[T collector](r *Registry, name string, c T) T {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if old, ok := r.collectors[name]; ok {
+		if same, ok := old.(T); ok {
+			return same
+		}
+		panic(fmt.Sprintf("metric %s registered with a different type", name))
+	}
+	r.collectors[name] = c
+	r.names = append(r.names, name)
+	return c
+}
+
+// 以Prometheus文本格式输出所有指标
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := make([]collector, len(r.names))
+	for i, name := range r.names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mutex.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// 输出指标的http处理函数,通常挂载在/metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// 默认注册表的http处理函数
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// 指标族的公共部分
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// 输出一个样本,extra为附加的标签(如le)
+func (d *desc) sample(w *bufio.Writer, suffix string, values []string, extra string, v float64) {
+	w.WriteString(d.name + suffix)
+	if len(d.labels) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, label := range d.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label + `="` + escape(values[i]) + `"`)
+		}
+		if extra != "" {
+			if len(d.labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// 标签值转义
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// 标签值数量必须与标签名一致
+func (d *desc) check(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// 按标签值排序的序列,保证输出顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// 只增不减的计数器
+type CounterVec struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// 注册计数器,labels为标签名
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return register(r, name, &CounterVec{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		values: make(map[string]float64),
+	})
+}
+
+// 计数加一,values为与标签名对应的标签值
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// 计数增加v,v<0时panic
+func (c *CounterVec) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("counter cannot decrease")
+	}
+	key := c.check(values)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[key] += v
+}
+
+// 当前计数
+func (c *CounterVec) Value(values ...string) float64 {
+	key := c.check(values)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.values) {
+		c.sample(w, "", splitKey(key, len(c.labels)), "", c.values[key])
+	}
+}
+
+func splitKey(key string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
+
+// 直方图,记录观测值的分布
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 //每个分桶内的观测数,不累加
+	sum    float64
+	count  uint64
+}
+
+// 注册直方图,buckets为升序的分桶上界,为空时使用DefBuckets
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return register(r, name, &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*histogram),
+	})
+}
+
+// 记录一次观测
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.check(values)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// 观测次数
+func (h *HistogramVec) Count(values ...string) uint64 {
+	key := h.check(values)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s, values := h.series[key], splitKey(key, len(h.labels))
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", values, `le="`+formatFloat(bound)+`"`, float64(cumulative))
+		}
+		h.sample(w, "_bucket", values, `le="+Inf"`, float64(s.count))
+		h.sample(w, "_sum", values, "", s.sum)
+		h.sample(w, "_count", values, "", float64(s.count))
+	}
+}
+
+// 采集时通过回调获取的样本
+type Sample struct {
+	Values []string //与标签名对应的标签值
+	Value  float64
+}
+
+// 采集时调用回调获取样本的指标,用于已在别处计数的值
+type funcCollector struct {
+	desc
+	fn func() []Sample
+}
+
+// 注册采集时调用fn的计数器
+func (r *Registry) CounterFunc(name, help string, labels []string, fn func() []Sample) {
+	register(r, name, &funcCollector{desc: desc{name: name, help: help, typ: "counter", labels: labels}, fn: fn})
+}
+
+// 注册采集时调用fn的仪表盘
+func (r *Registry) GaugeFunc(name, help string, labels []string, fn func() []Sample) {
+	register(r, name, &funcCollector{desc: desc{name: name, help: help, typ: "gauge", labels: labels}, fn: fn})
+}
+
+func (f *funcCollector) write(w *bufio.Writer) {
+	samples := f.fn()
+	slices.SortFunc(samples, func(a, b Sample) int {
+		return slices.Compare(a.Values, b.Values)
+	})
+	f.header(w)
+	for _, s := range samples {
+		f.check(s.Values)
+		f.sample(w, "", s.Values, "", s.Value)
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Total requests.", "method", "code")
+	requests.Inc("Get", "OK")
+	requests.Add(2, "Get", "OK")
+	requests.Inc("Put", `a"b`)
+	latency := r.Histogram("latency_seconds", "Request latency.", []float64{0.1, 1}, "method")
+	latency.Observe(0.05, "Get")
+	latency.Observe(0.5, "Get")
+	latency.Observe(5, "Get")
+	r.GaugeFunc("items", "Items in cache.", []string{"group"}, func() []Sample {
+		return []Sample{{Values: []string{"b"}, Value: 2}, {Values: []string{"a"}, Value: 1}}
+	})
+	if r.Counter("requests_total", "Total requests.", "method", "code") != requests {
+		t.Fatalf("registering the same counter twice should return the existing one")
+	}
+
+	Resp := httptest.NewRecorder()
+	r.Handler().ServeHTTP(Resp, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := Resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type = %q", ct)
+	}
+	body, _ := io.ReadAll(Resp.Body)
+	want := `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="Get",code="OK"} 3
+requests_total{method="Put",code="a\"b"} 1
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Get",le="0.1"} 1
+latency_seconds_bucket{method="Get",le="1"} 2
+latency_seconds_bucket{method="Get",le="+Inf"} 3
+latency_seconds_sum{method="Get"} 5.55
+latency_seconds_count{method="Get"} 3
+# HELP items Items in cache.
+# TYPE items gauge
+items{group="a"} 1
+items{group="b"} 2
+`
+	if string(body) != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestLabelMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("wrong number of label values should panic")
+		}
+	}()
+	NewRegistry().Counter("c", "c", "a").Inc()
+}
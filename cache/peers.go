@@ -1,11 +1,39 @@
 package cache
 
-import "github.com/LudensCS/Cache/cache/cachepb"
+import (
+	"context"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+)
 
 type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
+	AllPeers() []PeerGetter //除自身外的所有远端节点
+}
+
+// 能够选择key所属节点的节点选择器
+// PickPeer可能为了均衡负载选择其他节点,写入、删除与租约必须发往所属节点,未实现该接口时使用PickPeer
+type OwnerPicker interface {
+	PickOwner(key string) (peer PeerGetter, ok bool)
 }
 
+// 支持多副本的节点选择器
+// 返回key在哈希环上的前n个不同节点中的远端节点(按环上顺序),以及本节点是否在其中
+type ReplicaPicker interface {
+	PickReplicas(key string, n int) (peers []PeerGetter, self bool)
+}
+
+// 远端节点,ctx的超时与取消会随rpc传递到远端
 type PeerGetter interface {
-	Get(Req *cachepb.Request) (*cachepb.Response, error)
+	Get(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error)
+	Put(ctx context.Context, Req *cachepb.PutRequest) (*cachepb.Response, error)
+	Delete(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error)
+	Invalidate(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error)
+	GetBatch(ctx context.Context, Req *cachepb.BatchRequest) (*cachepb.BatchResponse, error)
+}
+
+// 支持租约的远端节点,Group.Lease与Group.Fill转发给key所属节点
+type LeaseGetter interface {
+	Lease(ctx context.Context, Req *cachepb.Request) (*cachepb.LeaseResponse, error)
+	Fill(ctx context.Context, Req *cachepb.FillRequest) (*cachepb.Response, error)
 }
@@ -0,0 +1,83 @@
+package placement
+
+import (
+	"slices"
+)
+
+// 跳跃一致性哈希(Jump Consistent Hash, Lamping & Veach)
+// 不需要额外内存,分布几乎完全均匀;节点按名称排序后编号,
+// 只有在编号末尾增删节点时移动的key最少,删除中间的节点会使其后所有节点的编号变化
+type JumpHash struct {
+	hash  Hash
+	nodes []string //sorted
+}
+
+// 创建JumpHash实例,fn为nil时使用默认哈希函数
+func NewJump(fn Hash) *JumpHash {
+	j := &JumpHash{hash: fn}
+	if j.hash == nil {
+		j.hash = defaultHash
+	}
+	return j
+}
+
+func (j *JumpHash) Add(nodes ...string) {
+	for _, node := range nodes {
+		if !slices.Contains(j.nodes, node) {
+			j.nodes = append(j.nodes, node)
+		}
+	}
+	slices.Sort(j.nodes)
+}
+
+func (j *JumpHash) Remove(nodes ...string) {
+	j.nodes = slices.DeleteFunc(j.nodes, func(node string) bool {
+		return slices.Contains(nodes, node)
+	})
+}
+
+func (j *JumpHash) Members() []string {
+	return slices.Clone(j.nodes)
+}
+
+// 将key映射到[0,buckets)中的一个桶
+func jump(key uint64, buckets int) int {
+	b, next := int64(-1), int64(0)
+	for next < int64(buckets) {
+		b = next
+		key = key*2862933555777941757 + 1
+		next = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (j *JumpHash) Get(key string) string {
+	if len(j.nodes) == 0 {
+		return ""
+	}
+	return j.nodes[jump(j.hash([]byte(key)), len(j.nodes))]
+}
+
+// 第i个副本使用key哈希值的第i次重新混合结果,得到已选中的节点时继续尝试,
+// 尝试次数用尽后按编号顺序补足
+func (j *JumpHash) GetN(key string, n int) []string {
+	if len(j.nodes) == 0 || n <= 0 {
+		return nil
+	}
+	n = min(n, len(j.nodes))
+	h := j.hash([]byte(key))
+	nodes := make([]string, 0, n)
+	for i := 0; i < 4*n && len(nodes) < n; i++ {
+		node := j.nodes[jump(h, len(j.nodes))]
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+		h = mix(h + 1)
+	}
+	for i := 0; len(nodes) < n; i++ {
+		if node := j.nodes[i]; !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
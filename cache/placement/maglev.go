@@ -0,0 +1,154 @@
+package placement
+
+import (
+	"slices"
+)
+
+const DefaultTableSize = 65537 //查找表大小,应为远大于节点数的质数
+
+// Maglev哈希(Google Maglev负载均衡器)
+// 每个节点按由自身哈希值决定的排列轮流填充查找表,查询只需一次取模与查表,分布几乎完全均匀;
+// 节点变化时需要O(M)重建查找表,移动的key略多于一致性哈希环
+// 带权重时每轮中节点填充的格数与权重成正比
+type MaglevHash struct {
+	hash    Hash
+	size    uint64
+	nodes   []string //sorted
+	weights map[string]int
+	table   []int //查找表,存储节点在nodes中的下标
+}
+
+// 创建MaglevHash实例,size为查找表大小,不是质数时向上取最近的质数,fn为nil时使用默认哈希函数
+func NewMaglev(size int, fn Hash) *MaglevHash {
+	m := &MaglevHash{
+		hash:    fn,
+		size:    nextPrime(uint64(max(size, 2))),
+		weights: make(map[string]int),
+	}
+	if m.hash == nil {
+		m.hash = defaultHash
+	}
+	return m
+}
+
+// 不小于n的最小质数,保证每个节点的排列能遍历整张查找表
+func nextPrime(n uint64) uint64 {
+	for ; ; n++ {
+		prime := true
+		for d := uint64(2); d*d <= n; d++ {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+func (m *MaglevHash) Add(nodes ...string) {
+	for _, node := range nodes {
+		m.weights[node] = 1
+	}
+	m.populate()
+}
+
+func (m *MaglevHash) AddWithWeight(node string, weight int) {
+	if weight <= 0 {
+		m.Remove(node)
+		return
+	}
+	m.weights[node] = weight
+	m.populate()
+}
+
+func (m *MaglevHash) SetWeight(node string, weight int) bool {
+	if _, ok := m.weights[node]; !ok {
+		return false
+	}
+	m.AddWithWeight(node, weight)
+	return true
+}
+
+func (m *MaglevHash) Weight(node string) int {
+	return m.weights[node]
+}
+
+func (m *MaglevHash) Remove(nodes ...string) {
+	for _, node := range nodes {
+		delete(m.weights, node)
+	}
+	m.populate()
+}
+
+func (m *MaglevHash) Members() []string {
+	return slices.Clone(m.nodes)
+}
+
+// 重建查找表
+func (m *MaglevHash) populate() {
+	m.nodes = m.nodes[:0]
+	for node := range m.weights {
+		m.nodes = append(m.nodes, node)
+	}
+	slices.Sort(m.nodes)
+	if len(m.nodes) == 0 {
+		m.table = nil
+		return
+	}
+	//每个节点的排列: (offset + j*skip) mod size
+	offsets := make([]uint64, len(m.nodes))
+	skips := make([]uint64, len(m.nodes))
+	next := make([]uint64, len(m.nodes))
+	for i, node := range m.nodes {
+		h := m.hash([]byte(node))
+		offsets[i] = h % m.size
+		skips[i] = mix(h)%(m.size-1) + 1
+	}
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	for filled := uint64(0); ; {
+		for i, node := range m.nodes {
+			for range m.weights[node] {
+				c := (offsets[i] + next[i]*skips[i]) % m.size
+				for table[c] >= 0 {
+					next[i]++
+					c = (offsets[i] + next[i]*skips[i]) % m.size
+				}
+				table[c] = i
+				next[i]++
+				if filled++; filled == m.size {
+					m.table = table
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *MaglevHash) Get(key string) string {
+	if len(m.nodes) == 0 {
+		return ""
+	}
+	return m.nodes[m.table[m.hash([]byte(key))%m.size]]
+}
+
+// 从key在查找表中的位置向后查找不同的节点
+func (m *MaglevHash) GetN(key string, n int) []string {
+	if len(m.nodes) == 0 || n <= 0 {
+		return nil
+	}
+	n = min(n, len(m.nodes))
+	idx := m.hash([]byte(key)) % m.size
+	nodes := make([]string, 0, n)
+	for i := uint64(0); i < m.size && len(nodes) < n; i++ {
+		node := m.nodes[m.table[(idx+i)%m.size]]
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,117 @@
+// 节点放置策略: 决定每个key由哪些节点负责
+// 所有节点在成员相同时必须得到相同的结果,因此实现中只使用确定性的哈希函数
+package placement
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/LudensCS/Cache/cache/consistenthash"
+)
+
+const defaultReplicas = 50 //一致性哈希环中每个节点的虚拟节点数
+
+type Placement interface {
+	Add(nodes ...string)             //添加节点,已存在的节点权重重置为1
+	Remove(nodes ...string)          //删除节点
+	Members() []string               //所有节点,按名称排序
+	Get(key string) string           //key所属的节点,没有节点时返回""
+	GetN(key string, n int) []string //key的前n个不同节点,第一个为Get返回的节点
+}
+
+// 支持权重的放置策略,节点分到的key的比例与权重成正比
+type Weighted interface {
+	Placement
+	AddWithWeight(node string, weight int) //添加或修改节点的权重,weight<=0时删除节点
+	SetWeight(node string, weight int) bool
+	Weight(node string) int
+}
+
+// 放置算法
+type Algorithm int
+
+const (
+	Ring       Algorithm = iota //一致性哈希环
+	Rendezvous                  //最高随机权重(HRW)
+	Jump                        //跳跃一致性哈希,节点按名称排序后编号,不支持权重
+	Maglev                      //Maglev查找表
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case Ring:
+		return "ring"
+	case Rendezvous:
+		return "rendezvous"
+	case Jump:
+		return "jump"
+	case Maglev:
+		return "maglev"
+	}
+	return "unknown"
+}
+
+// 使用默认参数创建放置策略
+func New(a Algorithm) Placement {
+	switch a {
+	case Rendezvous:
+		return NewRendezvous(nil)
+	case Jump:
+		return NewJump(nil)
+	case Maglev:
+		return NewMaglev(DefaultTableSize, nil)
+	}
+	return consistenthash.New(defaultReplicas, nil)
+}
+
+// 64位哈希函数
+type Hash func([]byte) uint64
+
+// FNV-1a后接splitmix64的混合函数,使相近的输入得到均匀分布的输出
+func defaultHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return mix(h.Sum64())
+}
+
+// splitmix64的混合函数
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// 节点的权重,不支持权重的放置策略中所有节点权重为1
+func weight(p Placement, node string) int {
+	if w, ok := p.(Weighted); ok {
+		return w.Weight(node)
+	}
+	return 1
+}
+
+// 有界负载: 每个节点的负载上限为ceil((1+epsilon)*(total+1)*权重占比)
+// 按GetN的顺序查找第一个负载未达上限的节点,一致性哈希环使用其自身的实现
+func GetBounded(p Placement, key string, load func(node string) int64, epsilon float64) string {
+	if b, ok := p.(interface {
+		GetBounded(key string, load func(node string) int64, epsilon float64) string
+	}); ok {
+		return b.GetBounded(key, load, epsilon)
+	}
+	members := p.Members()
+	var total int64
+	weights := 0
+	for _, node := range members {
+		total += load(node)
+		weights += weight(p, node)
+	}
+	for _, node := range p.GetN(key, len(members)) {
+		limit := math.Ceil((1 + epsilon) * float64(total+1) * float64(weight(p, node)) / float64(weights))
+		if float64(load(node)+1) <= limit {
+			return node
+		}
+	}
+	return p.Get(key)
+}
@@ -0,0 +1,171 @@
+package placement
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+var algorithms = []Algorithm{Ring, Rendezvous, Jump, Maglev}
+
+func nodes(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node%02d", i)
+	}
+	return nodes
+}
+
+// 统计每个节点分到的key数
+func counts(p Placement, keys int) map[string]int {
+	counts := make(map[string]int)
+	for i := range keys {
+		counts[p.Get("key"+strconv.Itoa(i))]++
+	}
+	return counts
+}
+
+func TestPlacement(t *testing.T) {
+	for _, a := range algorithms {
+		t.Run(a.String(), func(t *testing.T) {
+			p := New(a)
+			if p.Get("jack") != "" || len(p.GetN("jack", 2)) != 0 {
+				t.Fatalf("empty placement should yield nothing")
+			}
+			p.Add("node02", "node00", "node01", "node03", "node04")
+			p.Add("node01")
+			if members := p.Members(); !slices.Equal(members, nodes(5)) {
+				t.Fatalf("unexpected members %v", members)
+			}
+			for i := range 1000 {
+				key := strconv.Itoa(i)
+				replicas := p.GetN(key, 3)
+				if len(replicas) != 3 || replicas[0] != p.Get(key) || len(slices.Compact(slices.Sorted(slices.Values(replicas)))) != 3 {
+					t.Fatalf("GetN(%s) = %v, Get = %s", key, replicas, p.Get(key))
+				}
+			}
+			if all := p.GetN("jack", 10); len(all) != 5 {
+				t.Fatalf("GetN larger than members should yield all members, got %v", all)
+			}
+			p.Remove("node03")
+			for i := range 1000 {
+				if p.Get(strconv.Itoa(i)) == "node03" {
+					t.Fatalf("removed node should own nothing")
+				}
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	const keys = 100000
+	limits := map[Algorithm]float64{Ring: 1.4, Rendezvous: 1.1, Jump: 1.1, Maglev: 1.1}
+	for _, a := range algorithms {
+		p := New(a)
+		p.Add(nodes(10)...)
+		peak := 0
+		for _, c := range counts(p, keys) {
+			peak = max(peak, c)
+		}
+		if ratio := float64(peak) * 10 / keys; ratio > limits[a] {
+			t.Errorf("%s: max/avg %.3f exceeds %.2f", a, ratio, limits[a])
+		}
+	}
+}
+
+// 节点变化时移动的key的比例
+func moved(before, after Placement, keys int) float64 {
+	cnt := 0
+	for i := range keys {
+		key := "key" + strconv.Itoa(i)
+		if before.Get(key) != after.Get(key) {
+			cnt++
+		}
+	}
+	return float64(cnt) / float64(keys)
+}
+
+func TestMovement(t *testing.T) {
+	const keys = 20000
+	for _, a := range algorithms {
+		before, after := New(a), New(a)
+		before.Add(nodes(10)...)
+		after.Add(nodes(11)...)
+		//新增节点理想情况下分走1/11的key
+		if m := moved(before, after, keys); m > 1.5/11 {
+			t.Errorf("%s: adding a node moved %.3f of keys", a, m)
+		}
+	}
+	//环与HRW删除节点时其他节点上的key不会移动
+	for _, a := range []Algorithm{Ring, Rendezvous} {
+		p := New(a)
+		p.Add(nodes(10)...)
+		owners := make(map[string]string)
+		for i := range keys {
+			key := "key" + strconv.Itoa(i)
+			owners[key] = p.Get(key)
+		}
+		p.Remove("node04")
+		for key, owner := range owners {
+			if owner != "node04" && p.Get(key) != owner {
+				t.Fatalf("%s: %s moved from %s after removing node04", a, key, owner)
+			}
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	const keys, tolerance = 100000, 0.2
+	for _, a := range []Algorithm{Ring, Rendezvous, Maglev} {
+		p := New(a).(Weighted)
+		weights := map[string]int{"node00": 1, "node01": 2, "node02": 3}
+		for node, w := range weights {
+			p.AddWithWeight(node, w)
+		}
+		if !p.SetWeight("node00", 2) || p.SetWeight("node09", 1) || p.Weight("node00") != 2 {
+			t.Fatalf("%s: set weight failed", a)
+		}
+		weights["node00"] = 2
+		for node, c := range counts(p, keys) {
+			want := float64(weights[node]) / 7
+			if share := float64(c) / keys; share < want*(1-tolerance) || share > want*(1+tolerance) {
+				t.Errorf("%s: node %s with weight %d got share %.3f, want %.3f", a, node, weights[node], share, want)
+			}
+		}
+	}
+	if _, ok := New(Jump).(Weighted); ok {
+		t.Fatalf("jump hash should not support weights")
+	}
+}
+
+func TestGetBounded(t *testing.T) {
+	for _, a := range algorithms {
+		p := New(a)
+		p.Add(nodes(4)...)
+		loads := map[string]int64{}
+		load := func(node string) int64 {
+			return loads[node]
+		}
+		owner := p.Get("jack")
+		if GetBounded(p, "jack", load, 0.25) != owner {
+			t.Fatalf("%s: idle placement should route jack to its owner", a)
+		}
+		loads[owner] = 10
+		if got := GetBounded(p, "jack", load, 0.25); got == owner || got != p.GetN("jack", 2)[1] {
+			t.Fatalf("%s: overloaded owner should overflow to the next replica, got %s", a, got)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, a := range algorithms {
+		p := New(a)
+		p.Add(nodes(16)...)
+		b.Run(a.String(), func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				p.Get(strconv.Itoa(i))
+			}
+		})
+	}
+}
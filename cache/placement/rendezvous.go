@@ -0,0 +1,111 @@
+package placement
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// 最高随机权重哈希(Rendezvous/HRW)
+// 每个节点对key计算一个分数,分数最高的节点负责该key,节点变化时只有该节点上的key会移动
+// 带权重时分数为 weight/-ln(u),u为(0,1)内的均匀哈希值,节点分到的key的比例与权重成正比
+// 查询为O(n),适合节点数较少的集群
+type RendezvousHash struct {
+	hash    Hash
+	nodes   []string          //sorted
+	seeds   map[string]uint64 //节点名的哈希值
+	weights map[string]int
+}
+
+// 创建RendezvousHash实例,fn为nil时使用默认哈希函数
+func NewRendezvous(fn Hash) *RendezvousHash {
+	r := &RendezvousHash{
+		hash:    fn,
+		seeds:   make(map[string]uint64),
+		weights: make(map[string]int),
+	}
+	if r.hash == nil {
+		r.hash = defaultHash
+	}
+	return r
+}
+
+func (r *RendezvousHash) Add(nodes ...string) {
+	for _, node := range nodes {
+		r.AddWithWeight(node, 1)
+	}
+}
+
+func (r *RendezvousHash) AddWithWeight(node string, weight int) {
+	if weight <= 0 {
+		r.Remove(node)
+		return
+	}
+	if _, ok := r.weights[node]; !ok {
+		r.nodes = append(r.nodes, node)
+		slices.Sort(r.nodes)
+		r.seeds[node] = r.hash([]byte(node))
+	}
+	r.weights[node] = weight
+}
+
+func (r *RendezvousHash) SetWeight(node string, weight int) bool {
+	if _, ok := r.weights[node]; !ok {
+		return false
+	}
+	r.AddWithWeight(node, weight)
+	return true
+}
+
+func (r *RendezvousHash) Weight(node string) int {
+	return r.weights[node]
+}
+
+func (r *RendezvousHash) Remove(nodes ...string) {
+	for _, node := range nodes {
+		delete(r.weights, node)
+		delete(r.seeds, node)
+	}
+	r.nodes = slices.DeleteFunc(r.nodes, func(node string) bool {
+		_, ok := r.weights[node]
+		return !ok
+	})
+}
+
+func (r *RendezvousHash) Members() []string {
+	return slices.Clone(r.nodes)
+}
+
+// 节点对key的分数
+func (r *RendezvousHash) score(node string, h uint64) float64 {
+	u := (float64(mix(r.seeds[node]^h)>>11) + 0.5) / (1 << 53)
+	return float64(r.weights[node]) / -math.Log(u)
+}
+
+func (r *RendezvousHash) Get(key string) string {
+	h := r.hash([]byte(key))
+	best, bestScore := "", -1.0
+	for _, node := range r.nodes {
+		if s := r.score(node, h); s > bestScore {
+			best, bestScore = node, s
+		}
+	}
+	return best
+}
+
+// 按分数从高到低返回前n个节点
+func (r *RendezvousHash) GetN(key string, n int) []string {
+	if len(r.nodes) == 0 || n <= 0 {
+		return nil
+	}
+	h := r.hash([]byte(key))
+	nodes := slices.Clone(r.nodes)
+	scores := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		scores[node] = r.score(node, h)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return scores[nodes[i]] > scores[nodes[j]]
+	})
+	return nodes[:min(n, len(nodes))]
+}
@@ -2,110 +2,761 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/LudensCS/Cache/cache/cachepb"
-	"github.com/LudensCS/Cache/cache/consistenthash"
+	"github.com/LudensCS/Cache/cache/membership"
+	"github.com/LudensCS/Cache/cache/placement"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
 )
 
-const defaultReplicas = 50
+// 客户端连接参数: 空闲时定期发送心跳探测连接,断线后按指数退避重连
+var dialOptions = []grpc.DialOption{
+	grpc.WithTransportCredentials(insecure.NewCredentials()),
+	grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                30 * time.Second,
+		Timeout:             10 * time.Second,
+		PermitWithoutStream: true,
+	}),
+	grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff: backoff.Config{
+			BaseDelay:  100 * time.Millisecond,
+			Multiplier: 1.6,
+			Jitter:     0.2,
+			MaxDelay:   10 * time.Second,
+		},
+		MinConnectTimeout: 5 * time.Second,
+	}),
+	grpc.WithChainUnaryInterceptor(clientInterceptor),
+	grpc.WithStatsHandler(clientTraceHandler),
+}
+
+// 服务端连接参数,允许客户端在没有请求时发送心跳
+var serverOptions = []grpc.ServerOption{
+	grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             10 * time.Second,
+		PermitWithoutStream: true,
+	}),
+	grpc.KeepaliveParams(keepalive.ServerParameters{
+		Time:    time.Minute,
+		Timeout: 10 * time.Second,
+	}),
+	grpc.ChainUnaryInterceptor(serverInterceptor),
+	grpc.StatsHandler(serverTraceHandler),
+}
 
 type (
 	//rpc服务器
 	CacheServer struct {
 		cachepb.UnimplementedGroupCacheServer
+		cachepb.UnimplementedAdminServer
 		Self    string //Self example : http://localhost:8888
 		mutex   sync.Mutex
-		peers   *consistenthash.Map
+		peers   placement.Placement
 		Getters map[string]*CacheClient
+		server  *grpc.Server
+		members *membership.Memberlist
+		epsilon float64             //有界负载系数,0表示不限制节点负载
+		algo    placement.Algorithm //节点放置算法
+		load    atomic.Int64        //本节点正在处理的来自远端节点的请求数
+		logger  *slog.Logger
+		sampler logSampler //选择节点等每个请求都会产生的日志的采样
 	}
-	//rpc客户端
+	//rpc客户端,持有到远端节点的长连接,并发安全
 	CacheClient struct {
 		BaseURL string //BaseURL example : http://localhost:8888
+		mutex   sync.RWMutex
+		conn    *grpc.ClientConn
+		client  cachepb.GroupCacheClient
+		admin   cachepb.AdminClient
+		closed  bool         //Close后不再重新建立连接
+		load    atomic.Int64 //正在进行的rpc调用数
 	}
 )
 
+// NewCacheServer的可选配置项
+type ServerOption func(*CacheServer)
+
+// 选择节点放置算法,默认为一致性哈希环
+// 集群中所有节点必须使用相同的算法
+func WithPlacement(algo placement.Algorithm) ServerOption {
+	return func(CS *CacheServer) {
+		CS.algo = algo
+	}
+}
+
+// 使用有界负载的一致性哈希选择节点,每个节点的负载不超过平均负载的(1+epsilon)倍
+// 负载为本节点视角下的进行中请求数: 发往远端节点的rpc调用与本节点正在处理的远端请求
+// 超过上限的节点上的key沿哈希环顺时针溢出到后继节点,由后继节点直接加载,加载的值不放入后继节点的主缓存
+func WithBoundedLoad(epsilon float64) ServerOption {
+	return func(CS *CacheServer) {
+		CS.epsilon = epsilon
+	}
+}
+
+// 设置节点的日志,日志自动带有server属性,默认使用slog.Default(),传入DiscardLogger可关闭日志
+func WithServerLogger(logger *slog.Logger) ServerOption {
+	return func(CS *CacheServer) {
+		CS.logger = logger
+	}
+}
+
 // 构造函数
-func NewCacheServer(addr string) *CacheServer {
-	return &CacheServer{
+func NewCacheServer(addr string, opts ...ServerOption) *CacheServer {
+	CS := &CacheServer{
 		Self:    addr,
 		mutex:   sync.Mutex{},
 		peers:   nil,
 		Getters: make(map[string]*CacheClient),
+		logger:  slog.Default(),
+		sampler: logSampler{n: defaultLogSampling},
+	}
+	for _, opt := range opts {
+		opt(CS)
 	}
+	CS.logger = CS.logger.With("server", addr)
+	return CS
 }
 
-// 日志
+// 以Info级别记录日志
 func (CS *CacheServer) Log(format string, args ...any) {
-	log.Printf("Server [%s] : %s\n", CS.Self, fmt.Sprintf(format, args...))
+	CS.logger.Info(fmt.Sprintf(format, args...))
 }
 func (CS *CacheServer) Get(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	CS.load.Add(1)
+	defer CS.load.Add(-1)
 	group := GetGroup(Req.GetGroup())
 	if group == nil {
 		return &cachepb.Response{}, status.Error(codes.Internal, "group not found")
 	}
-	value, err := group.Get(Req.GetKey())
+	value, err := group.GetContext(withPeerRequest(ctx), Req.GetKey())
+	if errors.Is(err, ErrNotFound) {
+		return &cachepb.Response{}, status.Errorf(codes.NotFound, "%s not found", Req.GetKey())
+	}
 	if err != nil {
 		return &cachepb.Response{}, err
 	}
-	return &cachepb.Response{Value: value.ByteSlice()}, nil
+	return &cachepb.Response{Value: value.ByteSlice(), Stale: value.Stale()}, nil
+}
+
+// 将key-value写入本节点缓存
+func (CS *CacheServer) Put(ctx context.Context, Req *cachepb.PutRequest) (*cachepb.Response, error) {
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.Response{}, status.Error(codes.Internal, "group not found")
+	}
+	if err := group.SetLocally(Req.GetKey(), Req.GetValue()); err != nil {
+		return &cachepb.Response{}, err
+	}
+	return &cachepb.Response{}, nil
+}
+
+// 删除本节点缓存中的key
+func (CS *CacheServer) Delete(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.Response{}, status.Error(codes.Internal, "group not found")
+	}
+	group.RemoveLocally(Req.GetKey())
+	return &cachepb.Response{}, nil
+}
+
+// 使本节点缓存中的key失效,由发起Invalidate的节点广播
+func (CS *CacheServer) Invalidate(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	return CS.Delete(ctx, Req)
+}
+
+// 查询本节点所属的key,未命中时发放租约
+func (CS *CacheServer) Lease(ctx context.Context, Req *cachepb.Request) (*cachepb.LeaseResponse, error) {
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.LeaseResponse{}, status.Error(codes.Internal, "group not found")
+	}
+	value, token, err := group.LeaseLocally(ctx, Req.GetKey())
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &cachepb.LeaseResponse{}, status.Errorf(codes.NotFound, "%s not found", Req.GetKey())
+	case errors.Is(err, ErrLeaseHeld):
+		return &cachepb.LeaseResponse{}, status.Error(codes.Aborted, err.Error())
+	case err != nil:
+		return &cachepb.LeaseResponse{}, err
+	}
+	return &cachepb.LeaseResponse{Value: value.ByteSlice(), Token: token, Stale: value.Stale()}, nil
+}
+
+// 以租约回填本节点缓存
+func (CS *CacheServer) Fill(ctx context.Context, Req *cachepb.FillRequest) (*cachepb.Response, error) {
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.Response{}, status.Error(codes.Internal, "group not found")
+	}
+	err := group.FillLocally(Req.GetKey(), Req.GetValue(), Req.GetToken())
+	if errors.Is(err, ErrLeaseInvalid) {
+		return &cachepb.Response{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &cachepb.Response{}, err
+}
+
+// 批量查询,每个key的结果与错误单独返回
+func (CS *CacheServer) GetBatch(ctx context.Context, Req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+	CS.load.Add(1)
+	defer CS.load.Add(-1)
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.BatchResponse{}, status.Error(codes.Internal, "group not found")
+	}
+	values, errs := group.GetManyContext(withPeerRequest(ctx), Req.GetKeys())
+	Resp := &cachepb.BatchResponse{Results: make([]*cachepb.Result, len(values))}
+	for i, key := range Req.GetKeys() {
+		Resp.Results[i] = &cachepb.Result{Key: key}
+		if errors.Is(errs[i], ErrNotFound) {
+			Resp.Results[i].NotFound = true
+		} else if errs[i] != nil {
+			Resp.Results[i].Error = errs[i].Error()
+		} else {
+			Resp.Results[i].Value, Resp.Results[i].Stale = values[i].ByteSlice(), values[i].Stale()
+		}
+	}
+	return Resp, nil
+}
+
+// 查询本节点缓存组的统计信息
+func (CS *CacheServer) Stats(ctx context.Context, Req *cachepb.StatsRequest) (*cachepb.StatsResponse, error) {
+	group := GetGroup(Req.GetGroup())
+	if group == nil {
+		return &cachepb.StatsResponse{}, status.Error(codes.Internal, "group not found")
+	}
+	return group.Stats().proto(CS.Self), nil
+}
+
+// 汇总集群中所有节点上缓存组的统计信息,nodes为各节点的统计信息
+// 部分节点查询失败时返回其余节点的汇总结果与错误
+func (CS *CacheServer) ClusterStats(ctx context.Context, group string) (total Stats, nodes map[string]Stats, err error) {
+	nodes = make(map[string]Stats)
+	if g := GetGroup(group); g != nil {
+		nodes[CS.Self] = g.Stats()
+	}
+	CS.mutex.Lock()
+	getters := make([]*CacheClient, 0, len(CS.Getters))
+	for addr, getter := range CS.Getters {
+		if addr != CS.Self {
+			getters = append(getters, getter)
+		}
+	}
+	CS.mutex.Unlock()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	for _, getter := range getters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Resp, err := getter.Stats(ctx, &cachepb.StatsRequest{Group: group})
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stats %s : %w", getter.BaseURL, err))
+				return
+			}
+			nodes[getter.BaseURL] = statsFromProto(Resp)
+		}()
+	}
+	wg.Wait()
+	for _, s := range nodes {
+		total = total.Add(s)
+	}
+	return total, nodes, errors.Join(errs...)
 }
 
-// 注册分布式系统中的节点
+// 校验节点地址,地址格式为http://host:port
+func checkPeers(peers []string) error {
+	for _, peer := range peers {
+		if !strings.HasPrefix(peer, "http://") || len(peer) == len("http://") {
+			return status.Errorf(codes.InvalidArgument, "invalid peer address %q", peer)
+		}
+	}
+	return nil
+}
+
+// 管理接口: 添加节点
+func (CS *CacheServer) AddPeers(ctx context.Context, Req *cachepb.PeersRequest) (*cachepb.PeersResponse, error) {
+	if err := checkPeers(Req.GetPeers()); err != nil {
+		return &cachepb.PeersResponse{}, err
+	}
+	CS.Set(Req.GetPeers()...)
+	CS.logger.Info("add peers", "peers", Req.GetPeers())
+	return &cachepb.PeersResponse{Peers: CS.Peers()}, nil
+}
+
+// 管理接口: 移除节点
+func (CS *CacheServer) RemovePeers(ctx context.Context, Req *cachepb.PeersRequest) (*cachepb.PeersResponse, error) {
+	CS.RemovePeer(Req.GetPeers()...)
+	CS.logger.Info("remove peers", "peers", Req.GetPeers())
+	return &cachepb.PeersResponse{Peers: CS.Peers()}, nil
+}
+
+// 管理接口: 替换全部节点
+func (CS *CacheServer) SetPeers(ctx context.Context, Req *cachepb.PeersRequest) (*cachepb.PeersResponse, error) {
+	if err := checkPeers(Req.GetPeers()); err != nil {
+		return &cachepb.PeersResponse{}, err
+	}
+	CS.ReplacePeers(Req.GetPeers()...)
+	CS.logger.Info("set peers", "peers", Req.GetPeers())
+	return &cachepb.PeersResponse{Peers: CS.Peers()}, nil
+}
+
+// 管理接口: 修改节点的权重并转发给其他所有节点,使所有节点的放置结果一致
+// 之后加入的节点以权重1加入,需要重新调用
+func (CS *CacheServer) SetPeerWeight(ctx context.Context, Req *cachepb.WeightRequest) (*cachepb.PeersResponse, error) {
+	if !CS.SetWeight(Req.GetPeer(), int(Req.GetWeight())) {
+		return &cachepb.PeersResponse{}, status.Errorf(codes.InvalidArgument, "cannot set weight of peer %q to %d", Req.GetPeer(), Req.GetWeight())
+	}
+	CS.logger.Info("set peer weight", "peer", Req.GetPeer(), "weight", Req.GetWeight())
+	if Req.GetLocal() {
+		return &cachepb.PeersResponse{Peers: CS.Peers()}, nil
+	}
+	CS.mutex.Lock()
+	getters := make([]*CacheClient, 0, len(CS.Getters))
+	for addr, getter := range CS.Getters {
+		if addr != CS.Self {
+			getters = append(getters, getter)
+		}
+	}
+	CS.mutex.Unlock()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	for _, getter := range getters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := getter.SetPeerWeight(ctx, &cachepb.WeightRequest{Peer: Req.GetPeer(), Weight: Req.GetWeight(), Local: true})
+			if err != nil {
+				mutex.Lock()
+				defer mutex.Unlock()
+				errs = append(errs, fmt.Errorf("set weight on %s : %w", getter.BaseURL, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return &cachepb.PeersResponse{Peers: CS.Peers()}, errors.Join(errs...)
+}
+
+// 管理接口: 查询当前节点列表
+func (CS *CacheServer) ListPeers(ctx context.Context, Req *cachepb.PeersRequest) (*cachepb.PeersResponse, error) {
+	return &cachepb.PeersResponse{Peers: CS.Peers()}, nil
+}
+
+// 注册分布式系统中的节点,并与每个远端节点建立长连接,已注册的节点沿用原有的连接与权重
 func (CS *CacheServer) Set(peers ...string) {
 	CS.mutex.Lock()
 	defer CS.mutex.Unlock()
 	if CS.peers == nil {
-		CS.peers = consistenthash.New(defaultReplicas, nil)
+		CS.peers = placement.New(CS.algo)
+	}
+	for _, peer := range peers {
+		CS.place(CS.peers, peer)
+		if peer == CS.Self {
+			continue
+		}
+		if _, ok := CS.Getters[peer]; !ok {
+			CS.Getters[peer] = CS.newClient(peer)
+		}
+	}
+}
+
+// 创建到远端节点的客户端,建立连接失败时在首次调用时重试
+func (CS *CacheServer) newClient(peer string) *CacheClient {
+	client, err := NewCacheClient(peer)
+	if err != nil {
+		CS.logger.Error("failed to connect peer", "peer", peer, "err", err)
+		return &CacheClient{BaseURL: peer}
+	}
+	return client
+}
+
+// 从分布式系统中移除节点,并关闭与其的连接
+func (CS *CacheServer) RemovePeer(peers ...string) {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	if CS.peers != nil {
+		CS.peers.Remove(peers...)
 	}
-	CS.peers.Add(peers...)
 	for _, peer := range peers {
-		CS.Getters[peer] = &CacheClient{BaseURL: peer}
+		if client, ok := CS.Getters[peer]; ok {
+			client.Close()
+			delete(CS.Getters, peer)
+		}
 	}
 }
 
-// 利用一致性哈希选择远端节点
+// 将节点加入p,支持权重时沿用节点当前的权重,调用者需持有锁
+func (CS *CacheServer) place(p placement.Placement, peer string) {
+	w, ok := p.(placement.Weighted)
+	if !ok {
+		p.Add(peer)
+		return
+	}
+	weight := 1
+	if old, ok := CS.peers.(placement.Weighted); ok {
+		weight = max(old.Weight(peer), 1)
+	}
+	w.AddWithWeight(peer, weight)
+}
+
+// 用peers替换当前的全部节点,哈希环一次性重建
+// 保留节点的连接与权重被复用,被移除节点的连接会被关闭
+func (CS *CacheServer) ReplacePeers(peers ...string) {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	ring := placement.New(CS.algo)
+	for _, peer := range peers {
+		CS.place(ring, peer)
+	}
+	getters := make(map[string]*CacheClient, len(peers))
+	for _, peer := range peers {
+		if peer == CS.Self {
+			continue
+		}
+		if client, ok := CS.Getters[peer]; ok {
+			getters[peer] = client
+		} else if _, ok := getters[peer]; !ok {
+			getters[peer] = CS.newClient(peer)
+		}
+	}
+	for peer, client := range CS.Getters {
+		if _, ok := getters[peer]; !ok {
+			client.Close()
+		}
+	}
+	CS.peers, CS.Getters = ring, getters
+}
+
+// 修改节点的权重,节点分到的key的比例与权重成正比,节点不存在或放置算法不支持权重时返回false
+// 只修改本节点的放置结果,所有节点必须使用相同的权重,集群中应通过管理接口SetPeerWeight修改
+func (CS *CacheServer) SetWeight(peer string, weight int) bool {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	w, ok := CS.peers.(placement.Weighted)
+	if !ok || weight <= 0 {
+		return false
+	}
+	return w.SetWeight(peer, weight)
+}
+
+// 返回当前的全部节点,按地址排序
+func (CS *CacheServer) Peers() []string {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	if CS.peers == nil {
+		return []string{}
+	}
+	return CS.peers.Members()
+}
+
+// 使用gossip协议自动发现节点,存活节点变化时自动重建哈希环,需在Run之前调用
+func (CS *CacheServer) UseMembership(ml *membership.Memberlist) {
+	ml.OnChange = func(members []string) {
+		CS.logger.Info("members changed", "members", members)
+		CS.ReplacePeers(members...)
+	}
+	CS.mutex.Lock()
+	CS.members = ml
+	CS.mutex.Unlock()
+}
+
+// 利用一致性哈希选择远端节点,启用有界负载时可能选择所属节点的后继节点,只用于读取
 func (CS *CacheServer) PickPeer(key string) (PeerGetter, bool) {
+	return CS.pick(key, CS.epsilon > 0)
+}
+
+// 选择key的所属节点,不受有界负载影响,用于写入、删除与租约
+func (CS *CacheServer) PickOwner(key string) (PeerGetter, bool) {
+	return CS.pick(key, false)
+}
+
+// 选择key所在的远端节点,key属于本节点时返回false
+func (CS *CacheServer) pick(key string, bounded bool) (PeerGetter, bool) {
 	CS.mutex.Lock()
 	defer CS.mutex.Unlock()
-	if peer := CS.peers.Get(key); peer != "" && peer != CS.Self {
-		CS.Log("Pick peer %s", peer)
-		return CS.Getters[peer], true
+	if CS.peers == nil {
+		return nil, false
+	}
+	peer := ""
+	if bounded {
+		peer = placement.GetBounded(CS.peers, key, CS.peerLoad, CS.epsilon)
+	} else {
+		peer = CS.peers.Get(key)
+	}
+	if peer == "" || peer == CS.Self {
+		return nil, false
+	}
+	//节点已移除或服务已停止时没有对应的客户端,由本节点处理
+	client, ok := CS.Getters[peer]
+	if !ok {
+		return nil, false
 	}
-	return nil, false
+	CS.sampler.debug(CS.logger, "pick peer", "key", key, "peer", peer)
+	return client, true
+}
 
+// 本节点视角下节点的负载,调用者需持有锁
+func (CS *CacheServer) peerLoad(peer string) int64 {
+	if peer == CS.Self {
+		return CS.load.Load()
+	}
+	if client, ok := CS.Getters[peer]; ok {
+		return client.Load()
+	}
+	return 0
+}
+
+// 利用一致性哈希选择key的前n个副本节点
+func (CS *CacheServer) PickReplicas(key string, n int) (peers []PeerGetter, self bool) {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	if CS.peers == nil {
+		return nil, false
+	}
+	for _, peer := range CS.peers.GetN(key, n) {
+		if peer == CS.Self {
+			self = true
+		} else if client, ok := CS.Getters[peer]; ok {
+			peers = append(peers, client)
+		}
+	}
+	return peers, self
+}
+
+// 除自身外的所有远端节点
+func (CS *CacheServer) AllPeers() []PeerGetter {
+	CS.mutex.Lock()
+	defer CS.mutex.Unlock()
+	peers := make([]PeerGetter, 0, len(CS.Getters))
+	for addr, getter := range CS.Getters {
+		if addr != CS.Self {
+			peers = append(peers, getter)
+		}
+	}
+	return peers
 }
 
-// 启动rpc服务
+// 启动rpc服务,Stop后返回
 func (CS *CacheServer) Run() error {
-	S := grpc.NewServer()
+	S := grpc.NewServer(serverOptions...)
 	cachepb.RegisterGroupCacheServer(S, CS)
+	cachepb.RegisterAdminServer(S, CS)
+	CS.mutex.Lock()
+	ml := CS.members
+	CS.mutex.Unlock()
+	if ml != nil {
+		cachepb.RegisterGossipServer(S, ml)
+	}
 	listener, err := net.Listen("tcp", CS.Self[7:])
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
+	CS.mutex.Lock()
+	CS.server = S
+	CS.mutex.Unlock()
+	if ml != nil {
+		ml.Start()
+	}
 	return S.Serve(listener)
 }
 
-// 启动rpc客户端调用
-func (CC *CacheClient) Get(Req *cachepb.Request) (*cachepb.Response, error) {
-	conn, err := grpc.NewClient(CC.BaseURL[7:], grpc.WithTransportCredentials(insecure.NewCredentials()))
+// 停止rpc服务,等待进行中的请求结束,并关闭与所有远端节点的连接
+// 启用了gossip时先通知其他节点本节点离开,停止后所有key由本节点处理
+func (CS *CacheServer) Stop() {
+	CS.mutex.Lock()
+	ml := CS.members
+	CS.members = nil
+	CS.mutex.Unlock()
+	if ml != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := ml.Leave(ctx); err != nil {
+			CS.logger.Warn("failed to leave cluster", "err", err)
+		}
+		cancel()
+	}
+	CS.mutex.Lock()
+	S, getters := CS.server, CS.Getters
+	CS.server, CS.Getters = nil, make(map[string]*CacheClient)
+	CS.peers = placement.New(CS.algo)
+	CS.mutex.Unlock()
+	if S != nil {
+		S.GracefulStop()
+	}
+	for _, getter := range getters {
+		getter.Close()
+	}
+}
+
+// 构造函数,创建到远端节点的长连接,连接在后台建立并在断开后自动重连
+func NewCacheClient(BaseURL string) (*CacheClient, error) {
+	CC := &CacheClient{BaseURL: BaseURL}
+	if _, err := CC.connect(); err != nil {
+		return nil, err
+	}
+	return CC, nil
+}
+
+// 获取长连接,尚未建立时建立连接,Close后返回codes.Unavailable
+func (CC *CacheClient) connect() (cachepb.GroupCacheClient, error) {
+	CC.mutex.RLock()
+	client := CC.client
+	CC.mutex.RUnlock()
+	if client != nil {
+		return client, nil
+	}
+	CC.mutex.Lock()
+	defer CC.mutex.Unlock()
+	if CC.client != nil {
+		return CC.client, nil
+	}
+	if CC.closed {
+		return nil, status.Errorf(codes.Unavailable, "client to %s is closed", CC.BaseURL)
+	}
+	conn, err := grpc.NewClient(CC.BaseURL[7:], dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	conn.Connect()
+	CC.conn, CC.client, CC.admin = conn, cachepb.NewGroupCacheClient(conn), cachepb.NewAdminClient(conn)
+	return CC.client, nil
+}
+
+// 关闭长连接,之后的调用不再重新建立连接
+// 节点被移除或服务停止后,仍持有该客户端的调用方不会因此打开无人关闭的连接
+func (CC *CacheClient) Close() error {
+	CC.mutex.Lock()
+	defer CC.mutex.Unlock()
+	CC.closed = true
+	if CC.conn == nil {
+		return nil
+	}
+	err := CC.conn.Close()
+	CC.conn, CC.client, CC.admin = nil, nil, nil
+	return err
+}
+
+// 正在进行的rpc调用数
+func (CC *CacheClient) Load() int64 {
+	return CC.load.Load()
+}
+
+// 使用长连接发起一次rpc调用
+func (CC *CacheClient) call(fn func(client cachepb.GroupCacheClient) (*cachepb.Response, error)) (*cachepb.Response, error) {
+	CC.load.Add(1)
+	defer CC.load.Add(-1)
+	client, err := CC.connect()
 	if err != nil {
 		return &cachepb.Response{}, err
 	}
-	defer conn.Close()
-	client := cachepb.NewGroupCacheClient(conn)
-	Resp, err := client.Get(context.Background(), Req)
+	Resp, err := fn(client)
 	if err != nil {
 		return &cachepb.Response{}, err
 	}
 	return Resp, nil
 }
+
+// 启动rpc客户端调用
+func (CC *CacheClient) Get(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	return CC.call(func(client cachepb.GroupCacheClient) (*cachepb.Response, error) {
+		return client.Get(ctx, Req)
+	})
+}
+
+func (CC *CacheClient) Put(ctx context.Context, Req *cachepb.PutRequest) (*cachepb.Response, error) {
+	return CC.call(func(client cachepb.GroupCacheClient) (*cachepb.Response, error) {
+		return client.Put(ctx, Req)
+	})
+}
+
+func (CC *CacheClient) Delete(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	return CC.call(func(client cachepb.GroupCacheClient) (*cachepb.Response, error) {
+		return client.Delete(ctx, Req)
+	})
+}
+
+func (CC *CacheClient) Invalidate(ctx context.Context, Req *cachepb.Request) (*cachepb.Response, error) {
+	return CC.call(func(client cachepb.GroupCacheClient) (*cachepb.Response, error) {
+		return client.Invalidate(ctx, Req)
+	})
+}
+
+func (CC *CacheClient) GetBatch(ctx context.Context, Req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+	CC.load.Add(1)
+	defer CC.load.Add(-1)
+	client, err := CC.connect()
+	if err != nil {
+		return &cachepb.BatchResponse{}, err
+	}
+	Resp, err := client.GetBatch(ctx, Req)
+	if err != nil {
+		return &cachepb.BatchResponse{}, err
+	}
+	return Resp, nil
+}
+
+func (CC *CacheClient) Lease(ctx context.Context, Req *cachepb.Request) (*cachepb.LeaseResponse, error) {
+	CC.load.Add(1)
+	defer CC.load.Add(-1)
+	client, err := CC.connect()
+	if err != nil {
+		return &cachepb.LeaseResponse{}, err
+	}
+	Resp, err := client.Lease(ctx, Req)
+	if err != nil {
+		return &cachepb.LeaseResponse{}, err
+	}
+	return Resp, nil
+}
+
+func (CC *CacheClient) Fill(ctx context.Context, Req *cachepb.FillRequest) (*cachepb.Response, error) {
+	return CC.call(func(client cachepb.GroupCacheClient) (*cachepb.Response, error) {
+		return client.Fill(ctx, Req)
+	})
+}
+
+// 通知远端节点修改节点的权重
+func (CC *CacheClient) SetPeerWeight(ctx context.Context, Req *cachepb.WeightRequest) (*cachepb.PeersResponse, error) {
+	if _, err := CC.connect(); err != nil {
+		return &cachepb.PeersResponse{}, err
+	}
+	CC.mutex.RLock()
+	admin := CC.admin
+	CC.mutex.RUnlock()
+	if admin == nil {
+		return &cachepb.PeersResponse{}, status.Errorf(codes.Unavailable, "client to %s is closed", CC.BaseURL)
+	}
+	return admin.SetPeerWeight(ctx, Req)
+}
+
+// 查询远端节点缓存组的统计信息
+func (CC *CacheClient) Stats(ctx context.Context, Req *cachepb.StatsRequest) (*cachepb.StatsResponse, error) {
+	client, err := CC.connect()
+	if err != nil {
+		return &cachepb.StatsResponse{}, err
+	}
+	Resp, err := client.Stats(ctx, Req)
+	if err != nil {
+		return &cachepb.StatsResponse{}, err
+	}
+	return Resp, nil
+}
@@ -0,0 +1,547 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+	"github.com/LudensCS/Cache/cache/membership"
+	"github.com/LudensCS/Cache/cache/metrics"
+	"github.com/LudensCS/Cache/cache/placement"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 空闲端口的服务地址
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return "http://" + listener.Addr().String()
+}
+
+// 启动rpc服务并等待其开始监听,测试结束时停止
+func runTestServer(t *testing.T, server *CacheServer) *CacheServer {
+	go server.Run()
+	t.Cleanup(server.Stop)
+	for range 50 {
+		if conn, err := net.Dial("tcp", server.Self[7:]); err == nil {
+			conn.Close()
+			return server
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server %s not started", server.Self)
+	return nil
+}
+
+// 在空闲端口上启动rpc服务,返回服务地址
+func startTestServer(t *testing.T) string {
+	return runTestServer(t, NewCacheServer(freeAddr(t))).Self
+}
+
+func TestRPC(t *testing.T) {
+	NewGroup("rpc", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	client := &CacheClient{BaseURL: startTestServer(t)}
+
+	if Resp, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc", Key: "jack"}); err != nil || string(Resp.GetValue()) != "jack" {
+		t.Fatalf("rpc get jack failed: %v", err)
+	}
+	if _, err := client.Put(context.Background(), &cachepb.PutRequest{Group: "rpc", Key: "jack", Value: []byte("256")}); err != nil {
+		t.Fatal(err)
+	}
+	if Resp, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc", Key: "jack"}); err != nil || string(Resp.GetValue()) != "256" {
+		t.Fatalf("rpc put jack failed: %v", err)
+	}
+	if _, err := client.Delete(context.Background(), &cachepb.Request{Group: "rpc", Key: "jack"}); err != nil {
+		t.Fatal(err)
+	}
+	if Resp, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc", Key: "jack"}); err != nil || string(Resp.GetValue()) != "jack" {
+		t.Fatalf("rpc delete jack failed: %v", err)
+	}
+	Resp, err := client.GetBatch(context.Background(), &cachepb.BatchRequest{Group: "rpc", Keys: []string{"jack", "tom", ""}})
+	if err != nil || len(Resp.GetResults()) != 3 {
+		t.Fatalf("rpc get batch failed: %v", err)
+	}
+	if r := Resp.GetResults(); string(r[0].GetValue()) != "jack" || string(r[1].GetValue()) != "tom" || r[2].GetError() == "" {
+		t.Fatalf("rpc get batch returned wrong results %v", r)
+	}
+	if _, err := client.Get(context.Background(), &cachepb.Request{Group: "unknown", Key: "jack"}); err == nil {
+		t.Fatalf("rpc get from unknown group should fail")
+	}
+}
+
+func TestRPCNotFound(t *testing.T) {
+	NewGroup("rpc-notfound", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	client := &CacheClient{BaseURL: startTestServer(t)}
+	if _, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc-notfound", Key: "jack"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound, got %v", err)
+	}
+	Resp, err := client.GetBatch(context.Background(), &cachepb.BatchRequest{Group: "rpc-notfound", Keys: []string{"jack"}})
+	if err != nil || !Resp.GetResults()[0].GetNotFound() {
+		t.Fatalf("batch result should be not found: %v, %v", Resp, err)
+	}
+}
+
+func TestRPCStale(t *testing.T) {
+	var fail atomic.Bool
+	g := NewGroup("rpc-stale", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if fail.Load() {
+			return nil, fmt.Errorf("backend down")
+		}
+		return []byte(key), nil
+	}), WithTTL(20*time.Millisecond), WithStaleIfError(time.Second, 1<<10), WithLogger(DiscardLogger))
+	defer g.Shutdown(context.Background())
+	client := &CacheClient{BaseURL: startTestServer(t)}
+	client.Get(context.Background(), &cachepb.Request{Group: "rpc-stale", Key: "jack"})
+	fail.Store(true)
+	time.Sleep(30 * time.Millisecond)
+	Resp, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc-stale", Key: "jack"})
+	if err != nil || string(Resp.GetValue()) != "jack" || !Resp.GetStale() {
+		t.Fatalf("stale value should be returned with stale flag: %v, %v", Resp, err)
+	}
+	Batch, err := client.GetBatch(context.Background(), &cachepb.BatchRequest{Group: "rpc-stale", Keys: []string{"jack"}})
+	if err != nil || !Batch.GetResults()[0].GetStale() {
+		t.Fatalf("batch result should be stale: %v, %v", Batch, err)
+	}
+}
+
+func TestRPCLease(t *testing.T) {
+	NewGroup("rpc-lease", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithLeases(time.Second, 10*time.Millisecond), WithLogger(DiscardLogger))
+	client := &CacheClient{BaseURL: startTestServer(t)}
+	ctx := context.Background()
+	Resp, err := client.Lease(ctx, &cachepb.Request{Group: "rpc-lease", Key: "jack"})
+	if err != nil || Resp.GetToken() == 0 {
+		t.Fatalf("miss should hand out a lease: %v, %v", Resp, err)
+	}
+	if _, err := client.Lease(ctx, &cachepb.Request{Group: "rpc-lease", Key: "jack"}); !errors.Is(leaseError(err), ErrLeaseHeld) {
+		t.Fatalf("expect ErrLeaseHeld, got %v", err)
+	}
+	if _, err := client.Fill(ctx, &cachepb.FillRequest{Group: "rpc-lease", Key: "jack", Value: []byte("1"), Token: Resp.GetToken() + 1}); !errors.Is(leaseError(err), ErrLeaseInvalid) {
+		t.Fatalf("expect ErrLeaseInvalid, got %v", err)
+	}
+	if _, err := client.Fill(ctx, &cachepb.FillRequest{Group: "rpc-lease", Key: "jack", Value: []byte("1"), Token: Resp.GetToken()}); err != nil {
+		t.Fatal(err)
+	}
+	if Resp, err := client.Lease(ctx, &cachepb.Request{Group: "rpc-lease", Key: "jack"}); err != nil || Resp.GetToken() != 0 || string(Resp.GetValue()) != "1" {
+		t.Fatalf("filled value should be returned: %v, %v", Resp, err)
+	}
+}
+
+func TestRPCDeadline(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	NewGroup("rpc-deadline", 2<<10, ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		select {
+		case <-ctx.Done():
+			cancelled <- struct{}{}
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return []byte(key), nil
+		}
+	}))
+	client := &CacheClient{BaseURL: startTestServer(t)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.Get(ctx, &cachepb.Request{Group: "rpc-deadline", Key: "jack"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("deadline should propagate to the remote getter")
+	}
+}
+
+func TestRPCConnReuse(t *testing.T) {
+	NewGroup("rpc-conn", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	client, err := NewCacheClient(startTestServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn := client.conn
+	for _, key := range []string{"jack", "tom", "sam"} {
+		if Resp, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc-conn", Key: key}); err != nil || string(Resp.GetValue()) != key {
+			t.Fatalf("rpc get %s failed: %v", key, err)
+		}
+	}
+	if _, err := client.GetBatch(context.Background(), &cachepb.BatchRequest{Group: "rpc-conn", Keys: []string{"jack"}}); err != nil {
+		t.Fatal(err)
+	}
+	if client.conn != conn {
+		t.Fatalf("connection should be reused across calls")
+	}
+	//关闭后不再重新建立连接
+	client.Close()
+	if _, err := client.Get(context.Background(), &cachepb.Request{Group: "rpc-conn", Key: "jack"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expect Unavailable after close, got %v", err)
+	}
+	if client.conn != nil {
+		t.Fatalf("connection should not be re-established after close")
+	}
+}
+
+func TestServerStop(t *testing.T) {
+	addr := startTestServer(t)
+	server := NewCacheServer("http://127.0.0.1:0")
+	server.Set(server.Self, addr)
+	if _, ok := server.Getters[server.Self]; ok {
+		t.Fatalf("should not connect to self")
+	}
+	old := server.Getters[addr]
+	if old == nil || old.conn == nil {
+		t.Fatalf("expect a persistent connection to %s", addr)
+	}
+	//重复注册的节点沿用原有的连接,进行中的调用不受影响
+	server.Set(addr)
+	if server.Getters[addr] != old || old.conn == nil {
+		t.Fatalf("registered peer should keep its client")
+	}
+	client := server.Getters[addr]
+	server.Stop()
+	if client.conn != nil || len(server.Getters) != 0 {
+		t.Fatalf("stop should close all clients")
+	}
+	//停止后所有key由本节点处理,不会返回已关闭的客户端
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		if peer, ok := server.PickPeer(key); ok || peer != nil {
+			t.Fatalf("no peer should be picked after stop, got %v", peer)
+		}
+		if peers, _ := server.PickReplicas(key, 2); len(peers) != 0 {
+			t.Fatalf("no replica should be picked after stop, got %v", peers)
+		}
+	}
+}
+
+func TestMembership(t *testing.T) {
+	server := NewCacheServer("http://localhost:8001")
+	defer server.Stop()
+	server.Set(server.Self, "http://localhost:8002", "http://localhost:8003")
+	b := server.Getters["http://localhost:8002"]
+	c := server.Getters["http://localhost:8003"]
+
+	server.RemovePeer("http://localhost:8003")
+	if _, ok := server.Getters["http://localhost:8003"]; ok || c.conn != nil {
+		t.Fatalf("removed peer should be closed and forgotten")
+	}
+	for i := range 100 {
+		if peer, ok := server.PickPeer(strconv.Itoa(i)); ok && peer.(*CacheClient).BaseURL == "http://localhost:8003" {
+			t.Fatalf("key %d should not be routed to removed peer", i)
+		}
+	}
+
+	if !server.SetWeight("http://localhost:8002", 3) || server.SetWeight("http://localhost:8003", 3) {
+		t.Fatalf("only registered peers can be weighted")
+	}
+	server.ReplacePeers(server.Self, "http://localhost:8002", "http://localhost:8004")
+	if server.Getters["http://localhost:8002"] != b || b.conn == nil {
+		t.Fatalf("connection to kept peer should be reused")
+	}
+	if w := server.peers.(placement.Weighted); w.Weight("http://localhost:8002") != 3 || w.Weight("http://localhost:8004") != 1 {
+		t.Fatalf("weight of kept peer should be preserved")
+	}
+	want := []string{"http://localhost:8001", "http://localhost:8002", "http://localhost:8004"}
+	if peers := server.Peers(); !slices.Equal(peers, want) {
+		t.Fatalf("expect peers %v, got %v", want, peers)
+	}
+
+	server.ReplacePeers(server.Self)
+	if len(server.Getters) != 0 || b.conn != nil {
+		t.Fatalf("all remote peers should be closed")
+	}
+	if _, ok := server.PickPeer("jack"); ok {
+		t.Fatalf("single node should not pick remote peer")
+	}
+}
+
+func TestAdminRPC(t *testing.T) {
+	addr := startTestServer(t)
+	conn, err := grpc.NewClient(addr[7:], dialOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	admin := cachepb.NewAdminClient(conn)
+	ctx := context.Background()
+
+	Resp, err := admin.AddPeers(ctx, &cachepb.PeersRequest{Peers: []string{addr, "http://localhost:8002"}})
+	if err != nil || !slices.Equal(Resp.GetPeers(), []string{addr, "http://localhost:8002"}) {
+		t.Fatalf("add peers failed: %v %v", Resp.GetPeers(), err)
+	}
+	if _, err := admin.AddPeers(ctx, &cachepb.PeersRequest{Peers: []string{"localhost:8003"}}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect invalid argument, got %v", err)
+	}
+	if Resp, err := admin.RemovePeers(ctx, &cachepb.PeersRequest{Peers: []string{"http://localhost:8002"}}); err != nil || !slices.Equal(Resp.GetPeers(), []string{addr}) {
+		t.Fatalf("remove peers failed: %v %v", Resp.GetPeers(), err)
+	}
+	if _, err := admin.SetPeers(ctx, &cachepb.PeersRequest{Peers: []string{addr, "http://localhost:8003"}}); err != nil {
+		t.Fatal(err)
+	}
+	if Resp, err := admin.ListPeers(ctx, &cachepb.PeersRequest{}); err != nil || !slices.Equal(Resp.GetPeers(), []string{addr, "http://localhost:8003"}) {
+		t.Fatalf("list peers failed: %v %v", Resp.GetPeers(), err)
+	}
+}
+
+func TestAdminWeight(t *testing.T) {
+	a := runTestServer(t, NewCacheServer(freeAddr(t)))
+	b := runTestServer(t, NewCacheServer(freeAddr(t)))
+	a.Set(a.Self, b.Self)
+	b.Set(a.Self, b.Self)
+	admin := &CacheClient{BaseURL: a.Self}
+	defer admin.Close()
+	ctx := context.Background()
+
+	//修改权重后转发给其他节点,所有节点的权重一致
+	if _, err := admin.SetPeerWeight(ctx, &cachepb.WeightRequest{Peer: b.Self, Weight: 3}); err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range []*CacheServer{a, b} {
+		server.mutex.Lock()
+		weight := server.peers.(placement.Weighted).Weight(b.Self)
+		server.mutex.Unlock()
+		if weight != 3 {
+			t.Fatalf("weight of %s on %s = %d, want 3", b.Self, server.Self, weight)
+		}
+	}
+	if _, err := admin.SetPeerWeight(ctx, &cachepb.WeightRequest{Peer: b.Self, Weight: 0}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expect invalid argument, got %v", err)
+	}
+}
+
+func TestGossipMembership(t *testing.T) {
+	servers := make([]*CacheServer, 3)
+	addrs := make([]string, 3)
+	for i := range servers {
+		addrs[i] = freeAddr(t)
+		servers[i] = NewCacheServer(addrs[i])
+		servers[i].UseMembership(membership.New(addrs[i],
+			membership.WithProbeInterval(50*time.Millisecond),
+			membership.WithProbeTimeout(25*time.Millisecond),
+			membership.WithSuspectTimeout(200*time.Millisecond),
+		))
+		runTestServer(t, servers[i])
+	}
+	for _, server := range servers[1:] {
+		if err := server.members.Join(context.Background(), addrs[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slices.Sort(addrs)
+	waitPeers := func(servers []*CacheServer, want []string) {
+		t.Helper()
+		for range 500 {
+			converged := true
+			for _, server := range servers {
+				converged = converged && slices.Equal(server.Peers(), want)
+			}
+			if converged {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("peers not converged to %v", want)
+	}
+	waitPeers(servers, addrs)
+	if len(servers[0].AllPeers()) != 2 {
+		t.Fatalf("expect connections to 2 remote peers")
+	}
+
+	servers[2].Stop()
+	waitPeers(servers[:2], slices.DeleteFunc(addrs, func(addr string) bool {
+		return addr == servers[2].Self
+	}))
+}
+
+func TestBoundedLoadPicker(t *testing.T) {
+	server := NewCacheServer("http://localhost:8001", WithBoundedLoad(0.25))
+	defer server.Stop()
+	peers := []string{server.Self, "http://localhost:8002", "http://localhost:8003"}
+	server.Set(peers...)
+	owner := func(key string) string {
+		if peer, ok := server.PickPeer(key); ok {
+			return peer.(*CacheClient).BaseURL
+		}
+		return server.Self
+	}
+	//没有负载时与普通一致性哈希一致
+	hot := ""
+	for i := range 100 {
+		key := strconv.Itoa(i)
+		if owner(key) != server.peers.Get(key) {
+			t.Fatalf("idle server should route %s to its owner", key)
+		}
+		if server.peers.Get(key) == "http://localhost:8002" {
+			hot = key
+		}
+	}
+	//8002上的进行中请求超过上限后,它的key溢出到其他节点
+	server.Getters["http://localhost:8002"].load.Store(10)
+	if peer := owner(hot); peer == "http://localhost:8002" {
+		t.Fatalf("overloaded peer should not be picked for %s", hot)
+	}
+	//写入、删除与租约仍发往所属节点
+	if peer, ok := server.PickOwner(hot); !ok || peer.(*CacheClient).BaseURL != "http://localhost:8002" {
+		t.Fatalf("owner of %s should be picked regardless of load, got %v", hot, peer)
+	}
+	server.Getters["http://localhost:8002"].load.Store(0)
+	if peer := owner(hot); peer != "http://localhost:8002" {
+		t.Fatalf("%s should return to its owner, got %s", hot, peer)
+	}
+}
+
+func TestPlacementPicker(t *testing.T) {
+	peers := []string{"http://localhost:8001", "http://localhost:8002", "http://localhost:8003"}
+	for _, algo := range []placement.Algorithm{placement.Ring, placement.Rendezvous, placement.Jump, placement.Maglev} {
+		server := NewCacheServer(peers[0], WithPlacement(algo))
+		server.Set(peers...)
+		want := placement.New(algo)
+		want.Add(peers...)
+		for i := range 100 {
+			key := strconv.Itoa(i)
+			owner := server.Self
+			if peer, ok := server.PickPeer(key); ok {
+				owner = peer.(*CacheClient).BaseURL
+			}
+			if owner != want.Get(key) {
+				t.Fatalf("%s: %s should be owned by %s, got %s", algo, key, want.Get(key), owner)
+			}
+		}
+		if _, weighted := want.(placement.Weighted); server.SetWeight(peers[1], 2) != weighted {
+			t.Fatalf("%s: set weight should only work with weighted placement", algo)
+		}
+		server.Stop()
+	}
+}
+
+func TestClusterStats(t *testing.T) {
+	g := NewGroup("rpc-stats", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	for _, key := range []string{"jack", "tom", "jack"} {
+		g.Get(key)
+	}
+	//同一进程内的两个节点共享缓存组,汇总结果为单个节点的两倍
+	a := runTestServer(t, NewCacheServer(freeAddr(t)))
+	b := runTestServer(t, NewCacheServer(freeAddr(t)))
+	a.Set(a.Self, b.Self)
+
+	client := &CacheClient{BaseURL: b.Self}
+	Resp, err := client.Stats(context.Background(), &cachepb.StatsRequest{Group: "rpc-stats"})
+	if err != nil || Resp.GetAddr() != b.Self || Resp.GetGets() != 3 || Resp.GetHits() != 1 || Resp.GetLocalLoads() != 2 {
+		t.Fatalf("rpc stats = %v, err = %v", Resp, err)
+	}
+	total, nodes, err := a.ClusterStats(context.Background(), "rpc-stats")
+	if err != nil || len(nodes) != 2 {
+		t.Fatalf("cluster stats nodes = %v, err = %v", nodes, err)
+	}
+	if want := g.Stats().Add(g.Stats()); total != want {
+		t.Fatalf("cluster stats = %+v, want %+v", total, want)
+	}
+	if _, err := client.Stats(context.Background(), &cachepb.StatsRequest{Group: "unknown"}); err == nil {
+		t.Fatalf("stats of unknown group should fail")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	NewGroup("rpc-metrics", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	client, err := NewCacheClient(startTestServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Get(context.Background(), &cachepb.Request{Group: "rpc-metrics", Key: "jack"})
+	client.Get(context.Background(), &cachepb.Request{Group: "unknown", Key: "jack"})
+
+	Resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(Resp, httptest.NewRequest("GET", "/metrics", nil))
+	body := Resp.Body.String()
+	for _, want := range []string{
+		`cache_group_gets_total{group="rpc-metrics"} 1`,
+		`cache_group_misses_total{group="rpc-metrics"} 1`,
+		`cache_group_items{group="rpc-metrics"} 1`,
+		`cache_load_duration_seconds_count{group="rpc-metrics",source="local"} 1`,
+		`cache_grpc_server_handled_total{method="/protobuf.GroupCache/Get",code="Internal"}`,
+		`cache_grpc_client_handled_total{method="/protobuf.GroupCache/Get",code="OK"}`,
+		`cache_grpc_server_handling_seconds_bucket{method="/protobuf.GroupCache/Get",le="+Inf"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(prevProvider)
+	defer otel.SetTextMapPropagator(prevPropagator)
+	g := NewGroup("rpc-trace", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	//同一进程内两个节点共享缓存组,直接向remote发起请求以免singleflight等待自身
+	local := NewCacheServer(freeAddr(t))
+	remote := runTestServer(t, NewCacheServer(freeAddr(t)))
+	local.Set(remote.Self)
+	defer local.Stop()
+	peer, ok := local.PickPeer("jack")
+	if !ok {
+		t.Fatalf("jack should belong to remote")
+	}
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "GET /api", trace.WithSpanKind(trace.SpanKindServer))
+	if view, err := g.GetFromPeer(ctx, peer, "jack"); err != nil || view.String() != "jack" {
+		t.Fatalf("get jack failed: %v", err)
+	}
+	root.End()
+
+	//每个阶段的span都以上一阶段为父
+	chain := []string{"GET /api", "protobuf.GroupCache/Get", "protobuf.GroupCache/Get", "Group.Get", "Group.GetLocally"}
+	kinds := []trace.SpanKind{trace.SpanKindServer, trace.SpanKindClient, trace.SpanKindServer, trace.SpanKindInternal, trace.SpanKindInternal}
+	spans := exporter.GetSpans()
+	parent := trace.SpanID{}
+	for i, name := range chain {
+		j := slices.IndexFunc(spans, func(s tracetest.SpanStub) bool {
+			return s.Name == name && s.Parent.SpanID() == parent
+		})
+		if j < 0 {
+			t.Fatalf("span %q with parent %s not found in %+v", name, parent, spans)
+		}
+		if s := spans[j]; s.SpanContext.TraceID() != root.SpanContext().TraceID() || s.SpanKind != kinds[i] {
+			t.Fatalf("span %q = %+v", name, s)
+		}
+		parent = spans[j].SpanContext.SpanID()
+	}
+}
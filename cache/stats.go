@@ -0,0 +1,166 @@
+// 缓存组统计
+package cache
+
+import (
+	"sync/atomic"
+
+	"github.com/LudensCS/Cache/cache/cachepb"
+)
+
+// 缓存组的统计信息,缓存相关的计数包含主缓存、热点缓存与不存在的key的缓存
+type Stats struct {
+	Gets         int64 //查询的key数
+	Hits         int64 //缓存命中数,含NegativeHits
+	NegativeHits int64 //命中不存在的key的缓存的次数
+	StaleHits    int64 //返回软过期旧值的次数
+	Refreshes    int64 //后台刷新次数
+	StaleServes  int64 //加载失败时从宽限区返回旧值的次数
+	Misses       int64 //缓存未命中数
+	PeerLoads    int64 //从远端节点加载成功的次数
+	PeerErrors   int64 //从远端节点加载失败的次数
+	LocalLoads   int64 //从本地数据源加载成功的次数
+	LoadErrors   int64 //从本地数据源加载失败的次数
+	LoadsDeduped int64 //被singleflight合并而未实际加载的次数
+	Writes       int64 //写入数据源成功的次数
+	WriteErrors  int64 //写入数据源失败的次数
+	Coalesced    int64 //write-behind中被同一key后续写入覆盖的写入数
+	Pending      int64 //write-behind中未刷新的写入数
+	Leases       int64 //发放的租约数
+	LeaseRejects int64 //租约作废或过期而被拒绝的回填数
+	Evictions    int64 //因容量不足被淘汰的结点数
+	Bytes        int64 //缓存已使用的字节数
+	Items        int64 //缓存中的结点数
+}
+
+// 缓存组的计数器
+type groupStats struct {
+	gets         atomic.Int64
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	staleHits    atomic.Int64
+	refreshes    atomic.Int64
+	staleServes  atomic.Int64
+	misses       atomic.Int64
+	peerLoads    atomic.Int64
+	peerErrors   atomic.Int64
+	localLoads   atomic.Int64
+	loadErrors   atomic.Int64
+	loadsDeduped atomic.Int64
+	writes       atomic.Int64
+	writeErrors  atomic.Int64
+	coalesced    atomic.Int64
+	leases       atomic.Int64
+	leaseRejects atomic.Int64
+}
+
+// 缓存组的统计信息
+func (g *Group) Stats() Stats {
+	main, hot, neg := g.mainCache.Stats(), g.hotCache.Stats(), g.negCache.Stats()
+	s := Stats{
+		Gets:         g.stats.gets.Load(),
+		Hits:         g.stats.hits.Load(),
+		NegativeHits: g.stats.negativeHits.Load(),
+		StaleHits:    g.stats.staleHits.Load(),
+		Refreshes:    g.stats.refreshes.Load(),
+		StaleServes:  g.stats.staleServes.Load(),
+		Misses:       g.stats.misses.Load(),
+		PeerLoads:    g.stats.peerLoads.Load(),
+		PeerErrors:   g.stats.peerErrors.Load(),
+		LocalLoads:   g.stats.localLoads.Load(),
+		LoadErrors:   g.stats.loadErrors.Load(),
+		LoadsDeduped: g.stats.loadsDeduped.Load(),
+		Writes:       g.stats.writes.Load(),
+		WriteErrors:  g.stats.writeErrors.Load(),
+		Coalesced:    g.stats.coalesced.Load(),
+		Leases:       g.stats.leases.Load(),
+		LeaseRejects: g.stats.leaseRejects.Load(),
+		Evictions:    main.Evictions + hot.Evictions + neg.Evictions,
+		Bytes:        main.Bytes + hot.Bytes + neg.Bytes,
+		Items:        main.Items + hot.Items + neg.Items,
+	}
+	if g.writer != nil {
+		s.Pending = int64(g.writer.size())
+	}
+	return s
+}
+
+// 累加另一个节点的统计信息
+func (s Stats) Add(o Stats) Stats {
+	s.Gets += o.Gets
+	s.Hits += o.Hits
+	s.NegativeHits += o.NegativeHits
+	s.StaleHits += o.StaleHits
+	s.Refreshes += o.Refreshes
+	s.StaleServes += o.StaleServes
+	s.Misses += o.Misses
+	s.PeerLoads += o.PeerLoads
+	s.PeerErrors += o.PeerErrors
+	s.LocalLoads += o.LocalLoads
+	s.LoadErrors += o.LoadErrors
+	s.LoadsDeduped += o.LoadsDeduped
+	s.Writes += o.Writes
+	s.WriteErrors += o.WriteErrors
+	s.Coalesced += o.Coalesced
+	s.Pending += o.Pending
+	s.Leases += o.Leases
+	s.LeaseRejects += o.LeaseRejects
+	s.Evictions += o.Evictions
+	s.Bytes += o.Bytes
+	s.Items += o.Items
+	return s
+}
+
+// 转换为rpc消息
+func (s Stats) proto(addr string) *cachepb.StatsResponse {
+	return &cachepb.StatsResponse{
+		Addr:         addr,
+		Gets:         uint64(s.Gets),
+		Hits:         uint64(s.Hits),
+		NegativeHits: uint64(s.NegativeHits),
+		StaleHits:    uint64(s.StaleHits),
+		Refreshes:    uint64(s.Refreshes),
+		StaleServes:  uint64(s.StaleServes),
+		Misses:       uint64(s.Misses),
+		PeerLoads:    uint64(s.PeerLoads),
+		PeerErrors:   uint64(s.PeerErrors),
+		LocalLoads:   uint64(s.LocalLoads),
+		LoadErrors:   uint64(s.LoadErrors),
+		LoadsDeduped: uint64(s.LoadsDeduped),
+		Writes:       uint64(s.Writes),
+		WriteErrors:  uint64(s.WriteErrors),
+		Coalesced:    uint64(s.Coalesced),
+		Pending:      uint64(s.Pending),
+		Leases:       uint64(s.Leases),
+		LeaseRejects: uint64(s.LeaseRejects),
+		Evictions:    uint64(s.Evictions),
+		Bytes:        uint64(s.Bytes),
+		Items:        uint64(s.Items),
+	}
+}
+
+// 从rpc消息转换
+func statsFromProto(Resp *cachepb.StatsResponse) Stats {
+	return Stats{
+		Gets:         int64(Resp.GetGets()),
+		Hits:         int64(Resp.GetHits()),
+		NegativeHits: int64(Resp.GetNegativeHits()),
+		StaleHits:    int64(Resp.GetStaleHits()),
+		Refreshes:    int64(Resp.GetRefreshes()),
+		StaleServes:  int64(Resp.GetStaleServes()),
+		Misses:       int64(Resp.GetMisses()),
+		PeerLoads:    int64(Resp.GetPeerLoads()),
+		PeerErrors:   int64(Resp.GetPeerErrors()),
+		LocalLoads:   int64(Resp.GetLocalLoads()),
+		LoadErrors:   int64(Resp.GetLoadErrors()),
+		LoadsDeduped: int64(Resp.GetLoadsDeduped()),
+		Writes:       int64(Resp.GetWrites()),
+		WriteErrors:  int64(Resp.GetWriteErrors()),
+		Coalesced:    int64(Resp.GetCoalesced()),
+		Pending:      int64(Resp.GetPending()),
+		Leases:       int64(Resp.GetLeases()),
+		LeaseRejects: int64(Resp.GetLeaseRejects()),
+		Evictions:    int64(Resp.GetEvictions()),
+		Bytes:        int64(Resp.GetBytes()),
+		Items:        int64(Resp.GetItems()),
+	}
+}
@@ -0,0 +1,85 @@
+package tinylfu
+
+import (
+	"hash/maphash"
+)
+
+const (
+	depth      = 4  //count-min sketch的行数
+	maxCounter = 15 //计数器上限,超过后不再增长
+)
+
+// count-min sketch频率估计器
+// 每个key在depth行中各映射一个计数器,取最小值作为频率估计
+// 累计增长次数达到sampleSize后所有计数器减半,使历史热度随时间衰减
+type Sketch struct {
+	seed       maphash.Seed
+	rows       [depth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// 创建Sketch实例,width会被调整为2的幂
+func NewSketch(width int) *Sketch {
+	w := 1
+	for w < width {
+		w <<= 1
+	}
+	s := &Sketch{
+		seed:       maphash.MakeSeed(),
+		mask:       uint64(w - 1),
+		sampleSize: 10 * w,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// 计算key在每一行中的下标
+func (s *Sketch) indexes(key string) [depth]uint64 {
+	h := maphash.String(s.seed, key)
+	h1, h2 := h&0xffffffff, h>>32
+	var idx [depth]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+// 记录key的一次访问
+func (s *Sketch) Increment(key string) {
+	added := false
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < maxCounter {
+			s.rows[i][j]++
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.sampleSize {
+			s.Reset()
+		}
+	}
+}
+
+// 估计key的访问频率
+func (s *Sketch) Estimate(key string) int {
+	freq := maxCounter
+	for i, j := range s.indexes(key) {
+		freq = min(freq, int(s.rows[i][j]))
+	}
+	return freq
+}
+
+// 老化: 所有计数器减半
+func (s *Sketch) Reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
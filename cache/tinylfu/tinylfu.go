@@ -0,0 +1,199 @@
+// W-TinyLFU缓存
+// 新结点先进入容量很小的窗口LRU,被窗口淘汰时作为候选者与主缓存的淘汰者比较频率估计,
+// 只有比淘汰者更热的候选者才会被准入主缓存,防止只访问一次的key冲刷热点数据
+package tinylfu
+
+import (
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+	"github.com/LudensCS/Cache/cache/lru"
+)
+
+const (
+	windowRatio      = 0.01 //窗口LRU占总容量的比例
+	defaultEntrySize = 64   //估计sketch宽度时假设的平均结点字节数
+	minSketchWidth   = 1024
+)
+
+// W-TinyLFU cache
+type Cache struct {
+	window    *lru.Cache
+	main      *lru.Cache
+	mainBytes int64 //主缓存容量,0表示不限
+	sketch    *Sketch
+	OnEvicted eviction.EvictedFunc
+}
+
+// 缓存中存储的值,记录过期时间以便从窗口移入主缓存时保留剩余的ttl
+type item struct {
+	value  eviction.Value
+	expire time.Time
+}
+
+func (it *item) Len() int {
+	return it.value.Len()
+}
+
+// 按比例划分窗口与主缓存的容量
+func split(maxBytes int64) (windowBytes, mainBytes int64) {
+	if maxBytes > 0 {
+		windowBytes = max(int64(float64(maxBytes)*windowRatio), 1)
+	}
+	return windowBytes, maxBytes - windowBytes
+}
+
+// 构造函数
+func New(maxBytes int64, OnEvicted eviction.EvictedFunc) *Cache {
+	windowBytes, mainBytes := split(maxBytes)
+	c := &Cache{
+		mainBytes: mainBytes,
+		sketch:    NewSketch(max(int(maxBytes/defaultEntrySize), minSketchWidth)),
+		OnEvicted: OnEvicted,
+	}
+	c.window = lru.New(windowBytes, c.onWindowEvicted)
+	c.main = lru.New(c.mainBytes, c.onMainEvicted)
+	return c
+}
+
+// 窗口淘汰的结点作为候选者尝试进入主缓存
+func (c *Cache) onWindowEvicted(key string, value eviction.Value, reason eviction.Reason) {
+	it := value.(*item)
+	if reason == eviction.Removed {
+		c.evicted(key, it, reason)
+		return
+	}
+	if reason == eviction.Expired || eviction.IsExpired(it.expire, time.Now()) {
+		c.evicted(key, it, eviction.Expired)
+		return
+	}
+	if !c.Admit(key, int64(len(key))+int64(it.Len())) {
+		c.evicted(key, it, eviction.Capacity)
+		return
+	}
+	var ttl time.Duration
+	if !it.expire.IsZero() {
+		ttl = time.Until(it.expire)
+	}
+	c.main.AddWithTTL(key, it, ttl)
+}
+
+func (c *Cache) onMainEvicted(key string, value eviction.Value, reason eviction.Reason) {
+	c.evicted(key, value.(*item), reason)
+}
+
+func (c *Cache) evicted(key string, it *item, reason eviction.Reason) {
+	if c.OnEvicted != nil {
+		c.OnEvicted(key, it.value, reason)
+	}
+}
+
+// 判断大小为size的候选者candidate是否值得进入主缓存
+// 主缓存仍有空间时直接准入,否则候选者的频率需严格大于主缓存淘汰者
+func (c *Cache) Admit(candidate string, size int64) bool {
+	if c.mainBytes == 0 || c.main.Bytes()+size <= c.mainBytes {
+		return true
+	}
+	victim, _, ok := c.main.Oldest()
+	if !ok {
+		return true
+	}
+	return c.sketch.Estimate(candidate) > c.sketch.Estimate(victim)
+}
+
+// 从缓存中查询key,无论是否命中都记录一次访问
+func (c *Cache) Get(key string) (value eviction.Value, ok bool) {
+	c.sketch.Increment(key)
+	if v, ok := c.window.Get(key); ok {
+		return v.(*item).value, true
+	}
+	if v, ok := c.main.Get(key); ok {
+		return v.(*item).value, true
+	}
+	return nil, false
+}
+
+// 添加或修改缓存键值对,永不过期
+func (c *Cache) Add(key string, value eviction.Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加或修改缓存键值对,ttl<=0表示永不过期
+// 已在主缓存中的key原地更新,否则写入窗口
+func (c *Cache) AddWithTTL(key string, value eviction.Value, ttl time.Duration) {
+	it := &item{value: value, expire: eviction.Deadline(ttl)}
+	if _, ok := c.main.Get(key); ok {
+		c.main.AddWithTTL(key, it, ttl)
+		return
+	}
+	c.window.AddWithTTL(key, it, ttl)
+}
+
+// 清理所有已过期的结点,返回清理数量
+func (c *Cache) RemoveExpired() int {
+	return c.window.RemoveExpired() + c.main.RemoveExpired()
+}
+
+// 查询key但不记录访问,已过期的key视为未命中
+func (c *Cache) Peek(key string) (value eviction.Value, ok bool) {
+	if v, ok := c.window.Peek(key); ok {
+		return v.(*item).value, true
+	}
+	if v, ok := c.main.Peek(key); ok {
+		return v.(*item).value, true
+	}
+	return nil, false
+}
+
+// 判断key是否在缓存中,不记录访问
+func (c *Cache) Contains(key string) bool {
+	return c.window.Contains(key) || c.main.Contains(key)
+}
+
+// 删除key,返回key是否存在
+func (c *Cache) Remove(key string) bool {
+	return c.window.Remove(key) || c.main.Remove(key)
+}
+
+// 按淘汰顺序返回所有未过期的key,窗口中的key在前
+func (c *Cache) Keys() []string {
+	return append(c.window.Keys(), c.main.Keys()...)
+}
+
+// 按淘汰顺序遍历未过期的结点,窗口中的结点在前,fn返回false时停止
+func (c *Cache) Range(fn func(key string, value eviction.Value) bool) {
+	next := true
+	visit := func(key string, value eviction.Value) bool {
+		next = fn(key, value.(*item).value)
+		return next
+	}
+	c.window.Range(visit)
+	if next {
+		c.main.Range(visit)
+	}
+}
+
+// 调整缓存容量,返回因此被淘汰的结点数
+func (c *Cache) Resize(maxBytes int64) int {
+	before := c.Len()
+	windowBytes, mainBytes := split(maxBytes)
+	c.mainBytes = mainBytes
+	c.main.Resize(mainBytes)
+	c.window.Resize(windowBytes)
+	return before - c.Len()
+}
+
+// 清空缓存,保留频率统计
+func (c *Cache) Purge() {
+	c.window.Purge()
+	c.main.Purge()
+}
+
+func (c *Cache) Len() int {
+	return c.window.Len() + c.main.Len()
+}
+
+// 缓存已使用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.window.Bytes() + c.main.Bytes()
+}
@@ -0,0 +1,200 @@
+package tinylfu
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+	"github.com/LudensCS/Cache/cache/lru"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("1234"))
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+func TestSketch(t *testing.T) {
+	s := NewSketch(64)
+	for range 5 {
+		s.Increment("hot")
+	}
+	s.Increment("cold")
+	if s.Estimate("hot") != 5 || s.Estimate("cold") != 1 || s.Estimate("none") != 0 {
+		t.Fatalf("sketch estimate failed, hot=%d cold=%d", s.Estimate("hot"), s.Estimate("cold"))
+	}
+	for range 20 {
+		s.Increment("hot")
+	}
+	if s.Estimate("hot") != maxCounter {
+		t.Fatalf("sketch counter should saturate at %d", maxCounter)
+	}
+	s.Reset()
+	if s.Estimate("hot") != maxCounter/2 || s.Estimate("cold") != 0 {
+		t.Fatalf("sketch reset should halve counters")
+	}
+}
+func TestSketchAging(t *testing.T) {
+	s := NewSketch(16)
+	for range 10 {
+		s.Increment("key")
+	}
+	s.additions = s.sampleSize - 1
+	s.Increment("other")
+	if s.Estimate("key") != 5 {
+		t.Fatalf("sketch should age counters after %d additions", s.sampleSize)
+	}
+}
+func TestAdmission(t *testing.T) {
+	keys := make([]string, 0)
+	c := New(int64(400), func(key string, value eviction.Value, reason eviction.Reason) {
+		keys = append(keys, key)
+	})
+	//主缓存装满热点数据
+	for i := range 40 {
+		key := "h" + strconv.Itoa(i)
+		for range 3 {
+			c.Get(key)
+		}
+		c.Add(key, String("v"))
+	}
+	keys = keys[:0]
+	//只访问一次的冷数据不能挤出热点数据
+	for i := range 100 {
+		key := "c" + strconv.Itoa(i)
+		c.Get(key)
+		c.Add(key, String("v"))
+	}
+	for _, key := range keys {
+		if key[0] == 'h' {
+			t.Fatalf("hot key %s evicted by one-hit wonders", key)
+		}
+	}
+}
+func TestOnEvicted(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value eviction.Value, reason eviction.Reason) {
+		keys = append(keys, key)
+	}
+	c := New(int64(10), callback)
+	c.Add("key1", String("123456"))
+	c.Add("k2", String("k2"))
+
+	expect := []string{"key1"}
+
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s, got %s", expect, keys)
+	}
+}
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]eviction.Reason)
+	c := New(int64(0), func(key string, value eviction.Value, reason eviction.Reason) {
+		reasons[key] = reason
+	})
+	c.AddWithTTL("key1", String("1234"), 10*time.Millisecond)
+	c.AddWithTTL("key2", String("5678"), 10*time.Millisecond)
+	c.Add("key3", String("9"))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("key1"); ok || reasons["key1"] != eviction.Expired {
+		t.Fatalf("lazy expiration of key1 failed")
+	}
+	if n := c.RemoveExpired(); n != 1 || c.Len() != 1 {
+		t.Fatalf("RemoveExpired should remove 1 key, but %d removed", n)
+	}
+}
+
+// 按zipf分布生成访问序列
+func zipfTrace(n int, keys uint64) []string {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, keys-1)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = strconv.FormatUint(z.Uint64(), 10)
+	}
+	return trace
+}
+
+// 模拟Group的访问方式: 先查询,未命中再写入,返回命中率
+func hitRatio(c eviction.Policy, trace []string) float64 {
+	hits := 0
+	for _, key := range trace {
+		if _, ok := c.Get(key); ok {
+			hits++
+		} else {
+			c.Add(key, String("v"))
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func TestHitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 100000)
+	maxBytes := int64(1000 * 6)
+	lruRatio := hitRatio(lru.New(maxBytes, nil), trace)
+	lfuRatio := hitRatio(New(maxBytes, nil), trace)
+	if lfuRatio <= lruRatio {
+		t.Fatalf("tinylfu hit ratio %.4f should beat lru %.4f", lfuRatio, lruRatio)
+	}
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	trace := zipfTrace(100000, 100000)
+	for _, size := range []int64{100, 1000, 10000} {
+		maxBytes := size * 6
+		b.Run("LRU/"+strconv.FormatInt(size, 10), func(b *testing.B) {
+			var ratio float64
+			for range b.N {
+				ratio = hitRatio(lru.New(maxBytes, nil), trace)
+			}
+			b.ReportMetric(ratio*100, "hit%")
+		})
+		b.Run("TinyLFU/"+strconv.FormatInt(size, 10), func(b *testing.B) {
+			var ratio float64
+			for range b.N {
+				ratio = hitRatio(New(maxBytes, nil), trace)
+			}
+			b.ReportMetric(ratio*100, "hit%")
+		})
+	}
+}
+func TestRemove(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("1234"))
+	c.Add("key2", String("5678"))
+	if !c.Remove("key1") || c.Contains("key1") || c.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if c.Remove("key3") {
+		t.Fatalf("Remove nonexistent key3 should return false")
+	}
+	if v, ok := c.Peek("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("Peek key2 failed")
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"key2"}) {
+		t.Fatalf("Keys should be [key2], got %v", keys)
+	}
+}
+func TestResize(t *testing.T) {
+	c := New(int64(0), nil)
+	for i := range 10 {
+		c.Add("k"+strconv.Itoa(i), String("v"))
+	}
+	if n := c.Resize(15); n == 0 || c.Len() > 5 {
+		t.Fatalf("Resize should evict keys, %d evicted", n)
+	}
+	c.Purge()
+	if c.Len() != 0 || len(c.Keys()) != 0 {
+		t.Fatalf("Purge failed")
+	}
+}
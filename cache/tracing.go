@@ -0,0 +1,34 @@
+// 分布式追踪
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// 本包创建的span所属的instrumentation scope
+const tracerName = "github.com/LudensCS/Cache/cache"
+
+// gossip探测频繁且与请求无关,不追踪
+func traced(info *stats.RPCTagInfo) bool {
+	return !strings.HasPrefix(info.FullMethodName, "/protobuf.Gossip/")
+}
+
+// gRPC的追踪由otelgrpc完成,使用全局的TracerProvider与TextMapPropagator,
+// 服务端从metadata恢复调用方的追踪上下文,客户端通过metadata传播追踪上下文
+var (
+	serverTraceHandler = otelgrpc.NewServerHandler(otelgrpc.WithFilter(traced))
+	clientTraceHandler = otelgrpc.NewClientHandler(otelgrpc.WithFilter(traced))
+)
+
+// 缓存组操作的span,每次从全局TracerProvider获取tracer,以便在启动后注册的TracerProvider生效
+func (g *Group) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("group", g.name))
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
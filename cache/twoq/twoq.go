@@ -0,0 +1,275 @@
+package twoq
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+const (
+	defaultInRatio    = 0.25 //a1in占总容量的比例
+	defaultGhostRatio = 0.50 //a1out幽灵结点占总容量的比例
+)
+
+// 2Q cache
+// 新结点先进入FIFO队列a1in,被挤出后只在a1out中保留key;
+// 若在a1out中再次被写入则进入LRU队列am,一次性扫描的数据不会冲刷am中的热点数据
+// 按字节计算容量,maxBytes为0时不淘汰
+type Cache struct {
+	maxBytes  int64
+	inBytes   int64 //a1in的目标字节数
+	outBytes  int64 //a1out的最大字节数
+	a1in, am  *segment
+	a1out     *segment
+	cache     map[string]*list.Element
+	OnEvicted eviction.EvictedFunc
+}
+
+// 构造函数
+func New(maxBytes int64, OnEvicted eviction.EvictedFunc) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		inBytes:   int64(float64(maxBytes) * defaultInRatio),
+		outBytes:  int64(float64(maxBytes) * defaultGhostRatio),
+		a1in:      newSegment(),
+		am:        newSegment(),
+		a1out:     newSegment(),
+		cache:     make(map[string]*list.Element),
+		OnEvicted: OnEvicted,
+	}
+}
+
+// 链表,记录所含结点的总字节数
+type segment struct {
+	lst   *list.List
+	bytes int64
+}
+
+func newSegment() *segment {
+	return &segment{lst: list.New()}
+}
+
+// 链表存储的结点类型,幽灵结点的value为nil
+type entry struct {
+	key    string
+	value  eviction.Value
+	size   int64
+	expire time.Time //过期时间,零值表示永不过期
+	owner  *segment
+}
+
+// 将结点移动到seg的尾部
+func (c *Cache) moveTo(ele *list.Element, seg *segment) {
+	kv := ele.Value.(*entry)
+	kv.owner.lst.Remove(ele)
+	kv.owner.bytes -= kv.size
+	kv.owner = seg
+	seg.bytes += kv.size
+	c.cache[kv.key] = seg.lst.PushBack(kv)
+}
+
+// 从缓存中查询key,已过期的key视为未命中并被惰性删除
+// am中的结点会被移动到MRU端,a1in中的结点保持FIFO顺序
+func (c *Cache) Get(key string) (value eviction.Value, ok bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if kv.owner == c.a1out {
+		return nil, false
+	}
+	if eviction.IsExpired(kv.expire, time.Now()) {
+		c.removeElement(ele, eviction.Expired)
+		return nil, false
+	}
+	if kv.owner == c.am {
+		c.am.lst.MoveToBack(ele)
+	}
+	return kv.value, true
+}
+
+// 添加或修改缓存键值对,永不过期
+func (c *Cache) Add(key string, value eviction.Value) {
+	c.AddWithTTL(key, value, 0)
+}
+
+// 添加或修改缓存键值对,ttl<=0表示永不过期
+func (c *Cache) AddWithTTL(key string, value eviction.Value, ttl time.Duration) {
+	expire := eviction.Deadline(ttl)
+	size := int64(len(key)) + int64(value.Len())
+	ele, ok := c.cache[key]
+	if !ok {
+		kv := &entry{key: key, value: value, size: size, expire: expire, owner: c.a1in}
+		c.a1in.bytes += size
+		c.cache[key] = c.a1in.lst.PushBack(kv)
+		c.reclaim()
+		return
+	}
+	kv := ele.Value.(*entry)
+	kv.owner.bytes += size - kv.size
+	kv.value, kv.size, kv.expire = value, size, expire
+	switch kv.owner {
+	case c.am:
+		c.am.lst.MoveToBack(ele)
+	case c.a1out:
+		//近期被淘汰过又再次写入,提升为热点数据
+		c.moveTo(ele, c.am)
+	}
+	c.reclaim()
+}
+
+// 淘汰结点直到容量满足要求
+func (c *Cache) reclaim() {
+	for c.maxBytes != 0 && c.a1in.bytes+c.am.bytes > c.maxBytes {
+		c.RemoveOldest()
+	}
+}
+
+// 缓存淘汰: a1in超过目标容量时淘汰a1in队首并记入a1out,否则淘汰am的LRU结点
+func (c *Cache) RemoveOldest() {
+	if c.a1in.lst.Len() > 0 && (c.a1in.bytes > c.inBytes || c.am.lst.Len() == 0) {
+		ele := c.a1in.lst.Front()
+		kv := ele.Value.(*entry)
+		value := kv.value
+		kv.value = nil
+		c.moveTo(ele, c.a1out)
+		c.trimGhosts()
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, value, eviction.Capacity)
+		}
+	} else if c.am.lst.Len() > 0 {
+		c.removeElement(c.am.lst.Front(), eviction.Capacity)
+	}
+}
+
+// 限制a1out中幽灵结点的规模
+func (c *Cache) trimGhosts() {
+	for c.a1out.bytes > c.outBytes && c.a1out.lst.Len() > 0 {
+		ghost := c.a1out.lst.Front()
+		c.a1out.lst.Remove(ghost)
+		c.a1out.bytes -= ghost.Value.(*entry).size
+		delete(c.cache, ghost.Value.(*entry).key)
+	}
+}
+
+// 删除缓存结点并触发回调
+func (c *Cache) removeElement(ele *list.Element, reason eviction.Reason) {
+	kv := ele.Value.(*entry)
+	kv.owner.lst.Remove(ele)
+	kv.owner.bytes -= kv.size
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+// 清理所有已过期的结点,返回清理数量
+func (c *Cache) RemoveExpired() int {
+	now, cnt := time.Now(), 0
+	for _, seg := range []*segment{c.a1in, c.am} {
+		for ele := seg.lst.Front(); ele != nil; {
+			next := ele.Next()
+			if eviction.IsExpired(ele.Value.(*entry).expire, now) {
+				c.removeElement(ele, eviction.Expired)
+				cnt++
+			}
+			ele = next
+		}
+	}
+	return cnt
+}
+
+// 查询key但不改变其访问顺序,已过期的key视为未命中
+func (c *Cache) Peek(key string) (value eviction.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if kv.owner == c.a1out || eviction.IsExpired(kv.expire, time.Now()) {
+			return nil, false
+		}
+		return kv.value, true
+	}
+	return nil, false
+}
+
+// 判断key是否在缓存中,不改变其访问顺序
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// 删除key,返回key是否存在,幽灵结点会被直接丢弃
+func (c *Cache) Remove(key string) bool {
+	ele, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ele.Value.(*entry)
+	if kv.value == nil {
+		kv.owner.lst.Remove(ele)
+		kv.owner.bytes -= kv.size
+		delete(c.cache, key)
+		return false
+	}
+	c.removeElement(ele, eviction.Removed)
+	return true
+}
+
+// 按淘汰顺序返回所有未过期的key
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.Len())
+	c.Range(func(key string, value eviction.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// 按淘汰顺序遍历未过期的结点,fn返回false时停止
+func (c *Cache) Range(fn func(key string, value eviction.Value) bool) {
+	now := time.Now()
+	for _, seg := range []*segment{c.a1in, c.am} {
+		for ele := seg.lst.Front(); ele != nil; ele = ele.Next() {
+			kv := ele.Value.(*entry)
+			if eviction.IsExpired(kv.expire, now) {
+				continue
+			}
+			if !fn(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// 调整缓存容量,返回因此被淘汰的结点数
+func (c *Cache) Resize(maxBytes int64) int {
+	before := c.Len()
+	c.maxBytes = maxBytes
+	c.inBytes = int64(float64(maxBytes) * defaultInRatio)
+	c.outBytes = int64(float64(maxBytes) * defaultGhostRatio)
+	c.reclaim()
+	c.trimGhosts()
+	return before - c.Len()
+}
+
+// 清空缓存与幽灵结点,对每个缓存结点触发回调
+func (c *Cache) Purge() {
+	for _, seg := range []*segment{c.a1in, c.am} {
+		for seg.lst.Len() > 0 {
+			c.removeElement(seg.lst.Front(), eviction.Removed)
+		}
+	}
+	clear(c.cache)
+	c.a1out = newSegment()
+}
+
+// 缓存中的结点数,不含幽灵结点
+func (c *Cache) Len() int {
+	return c.a1in.lst.Len() + c.am.lst.Len()
+}
+
+// 缓存已使用的字节数,不含a1out
+func (c *Cache) Bytes() int64 {
+	return c.a1in.bytes + c.am.bytes
+}
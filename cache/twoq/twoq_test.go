@@ -0,0 +1,126 @@
+package twoq
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LudensCS/Cache/cache/eviction"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+func TestGet(t *testing.T) {
+	q := New(int64(0), nil)
+	q.Add("key1", String("1234"))
+	if v, ok := q.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := q.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+func TestRemoveoldest(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "k3"
+	v1, v2, v3 := "value1", "value2", "v3"
+	cap := len(k1 + k2 + v1 + v2)
+	q := New(int64(cap), nil)
+	q.Add(k1, String(v1))
+	q.Add(k2, String(v2))
+	q.Add(k3, String(v3))
+
+	if _, ok := q.Get("key1"); ok || q.Len() != 2 {
+		t.Fatalf("Removeoldest key1 failed")
+	}
+}
+func TestOnEvicted(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value eviction.Value, reason eviction.Reason) {
+		keys = append(keys, key)
+	}
+	q := New(int64(10), callback)
+	q.Add("key1", String("123456"))
+	q.Add("k2", String("k2"))
+	q.Add("k3", String("k3"))
+	q.Add("k4", String("k4"))
+
+	expect := []string{"key1", "k2"}
+
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
+	}
+}
+func TestScanResistance(t *testing.T) {
+	q := New(int64(40), nil)
+	hot := []string{"h1", "h2", "h3"}
+	for _, key := range hot {
+		q.Add(key, String("vv"))
+	}
+	//挤出a1in后再次写入,进入am
+	for i := range 10 {
+		q.Add(fmt.Sprintf("w%d", i), String("v"))
+	}
+	for _, key := range hot {
+		q.Add(key, String("vv"))
+	}
+	//一次性扫描大量冷数据
+	for i := range 100 {
+		q.Add(fmt.Sprintf("c%02d", i), String("v"))
+	}
+	for _, key := range hot {
+		if _, ok := q.Get(key); !ok {
+			t.Fatalf("hot key %s flushed by scan", key)
+		}
+	}
+}
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]eviction.Reason)
+	q := New(int64(0), func(key string, value eviction.Value, reason eviction.Reason) {
+		reasons[key] = reason
+	})
+	q.AddWithTTL("key1", String("1234"), 10*time.Millisecond)
+	q.AddWithTTL("key2", String("5678"), 10*time.Millisecond)
+	q.Add("key3", String("9"))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := q.Get("key1"); ok || reasons["key1"] != eviction.Expired {
+		t.Fatalf("lazy expiration of key1 failed")
+	}
+	if n := q.RemoveExpired(); n != 1 || q.Len() != 1 {
+		t.Fatalf("RemoveExpired should remove 1 key, but %d removed", n)
+	}
+}
+func TestRemove(t *testing.T) {
+	q := New(int64(0), nil)
+	q.Add("key1", String("1234"))
+	q.Add("key2", String("5678"))
+	if !q.Remove("key1") || q.Contains("key1") || q.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if q.Remove("key3") {
+		t.Fatalf("Remove nonexistent key3 should return false")
+	}
+	if v, ok := q.Peek("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("Peek key2 failed")
+	}
+	if keys := q.Keys(); !reflect.DeepEqual(keys, []string{"key2"}) {
+		t.Fatalf("Keys should be [key2], got %v", keys)
+	}
+}
+func TestResize(t *testing.T) {
+	q := New(int64(0), nil)
+	for i := range 10 {
+		q.Add("k"+strconv.Itoa(i), String("v"))
+	}
+	if n := q.Resize(15); n == 0 || q.Len() > 5 {
+		t.Fatalf("Resize should evict keys, %d evicted", n)
+	}
+	q.Purge()
+	if q.Len() != 0 || len(q.Keys()) != 0 {
+		t.Fatalf("Purge failed")
+	}
+}
@@ -0,0 +1,299 @@
+// 写入数据源: write-through与write-behind
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 支持写入的数据源,启用write-through或write-behind时Getter必须实现该接口
+type Setter interface {
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// 支持删除的数据源,未实现时Group.Delete只删除缓存
+type Deleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// 支持批量写入的数据源,write-behind刷新时每批只调用一次,返回的错误与keys一一对应
+type BatchSetter interface {
+	SetMany(ctx context.Context, keys []string, values [][]byte) []error
+}
+
+// 写入模式
+type writeMode int
+
+const (
+	writeAround  writeMode = iota //只写缓存,数据源由调用方维护
+	writeThrough                  //先写数据源,成功后再写缓存
+	writeBehind                   //先写缓存,再异步批量写入数据源
+)
+
+const writeBatchSize = 128 //write-behind每批写入的最大key数,待写入的key达到该数量时提前刷新
+
+// Group.Set与Group.Delete先写入数据源,成功后再更新缓存,数据源写入失败时返回错误且缓存不变
+func WithWriteThrough() GroupOption {
+	return func(g *Group) {
+		g.write = writeThrough
+	}
+}
+
+// Group.Set与Group.Delete立即更新缓存,每隔interval(必须大于0)在后台将写入批量刷新到数据源
+// 同一key的多次写入合并为最后一次,写入失败的key在之后的周期重试,最多重试maxRetries次
+// 未刷新的写入只保存在调用Set的节点上,该节点从数据源加载时优先返回未刷新的值,关闭前需调用Group.Shutdown
+func WithWriteBehind(interval time.Duration, maxRetries int) GroupOption {
+	if interval <= 0 {
+		panic("write-behind interval must be positive!")
+	}
+	return func(g *Group) {
+		g.write = writeBehind
+		g.writer = &writeBuffer{interval: interval, retries: maxRetries}
+	}
+}
+
+// 检查数据源是否支持写入
+func (g *Group) initWriter() {
+	if g.write == writeAround {
+		return
+	}
+	setter, ok := g.getter.(Setter)
+	if !ok {
+		panic("getter does not implement Setter!")
+	}
+	g.setter = setter
+	g.deleter, _ = g.getter.(Deleter)
+	if wb := g.writer; wb != nil {
+		wb.g = g
+		wb.pending = make(map[string]*pendingWrite)
+		wb.kick = make(chan struct{}, 1)
+		wb.done = make(chan struct{})
+		wb.stopped = make(chan struct{})
+		g.cgetter = wb.wrap(g.cgetter)
+		go wb.loop()
+	}
+}
+
+// 按写入模式将写入持久化到数据源
+func (g *Group) persist(ctx context.Context, key string, value []byte) error {
+	switch g.write {
+	case writeThrough:
+		if err := g.setter.Set(ctx, key, value); err != nil {
+			g.stats.writeErrors.Add(1)
+			return err
+		}
+		g.stats.writes.Add(1)
+	case writeBehind:
+		g.writer.add(key, &pendingWrite{value: CloneBytes(value)})
+	}
+	return nil
+}
+
+// 按写入模式从数据源删除key,数据源不支持删除时只删除缓存
+func (g *Group) unpersist(ctx context.Context, key string) error {
+	if g.deleter == nil {
+		//丢弃未刷新的写入,避免被删除的值从队列中被读到或随后被写入数据源
+		if g.writer != nil {
+			g.writer.drop(key)
+		}
+		return nil
+	}
+	switch g.write {
+	case writeThrough:
+		if err := g.deleter.Delete(ctx, key); err != nil {
+			g.stats.writeErrors.Add(1)
+			return err
+		}
+		g.stats.writes.Add(1)
+	case writeBehind:
+		g.writer.add(key, &pendingWrite{delete: true})
+	}
+	return nil
+}
+
+// 立即将write-behind中未刷新的写入写入数据源,未启用write-behind时直接返回
+func (g *Group) Flush(ctx context.Context) error {
+	if g.writer == nil {
+		return nil
+	}
+	return g.writer.flush(ctx)
+}
+
+// 一次未刷新的写入
+type pendingWrite struct {
+	value    []byte
+	delete   bool //删除key
+	attempts int  //已失败的次数
+}
+
+// write-behind的待写入队列,同一key只保留最后一次写入
+type writeBuffer struct {
+	g        *Group
+	interval time.Duration
+	retries  int
+
+	mutex    sync.Mutex
+	pending  map[string]*pendingWrite
+	flushing sync.Mutex //同一时间只有一个刷新,避免同一写入被重复写入数据源
+	kick     chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stop     sync.Once
+}
+
+// 加入待写入队列,覆盖同一key未刷新的写入
+func (wb *writeBuffer) add(key string, w *pendingWrite) {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+	if _, ok := wb.pending[key]; ok {
+		wb.g.stats.coalesced.Add(1)
+	}
+	wb.pending[key] = w
+	if len(wb.pending) >= writeBatchSize {
+		select {
+		case wb.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 移出key未刷新的写入
+func (wb *writeBuffer) drop(key string) {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+	delete(wb.pending, key)
+}
+
+// 查询key未刷新的写入
+func (wb *writeBuffer) get(key string) (*pendingWrite, bool) {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+	w, ok := wb.pending[key]
+	return w, ok
+}
+
+// 未刷新的写入数
+func (wb *writeBuffer) size() int {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+	return len(wb.pending)
+}
+
+// 从数据源加载前先查询未刷新的写入,使本节点能读到自己的写入
+func (wb *writeBuffer) wrap(getter ContextGetter) ContextGetter {
+	return ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if w, ok := wb.get(key); ok {
+			if w.delete {
+				return nil, ErrNotFound
+			}
+			return w.value, nil
+		}
+		return getter.GetContext(ctx, key)
+	})
+}
+
+// 后台协程,定期或待写入的key达到批量大小时刷新
+func (wb *writeBuffer) loop() {
+	defer close(wb.stopped)
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wb.done:
+			return
+		case <-ticker.C:
+		case <-wb.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), wb.interval)
+		if err := wb.flush(ctx); err != nil {
+			wb.g.logger.Warn("failed to flush writes", "err", err)
+		}
+		cancel()
+	}
+}
+
+// 将当前所有未刷新的写入分批写入数据源
+// 写入期间值仍保留在队列中,成功后若未被新的写入覆盖则移出队列,失败时留在队列中等待重试
+func (wb *writeBuffer) flush(ctx context.Context) error {
+	wb.flushing.Lock()
+	defer wb.flushing.Unlock()
+	wb.mutex.Lock()
+	keys := make([]string, 0, len(wb.pending))
+	writes := make([]*pendingWrite, 0, len(wb.pending))
+	for key, w := range wb.pending {
+		keys = append(keys, key)
+		writes = append(writes, w)
+	}
+	wb.mutex.Unlock()
+	var errs []error
+	for i := 0; i < len(keys); i += writeBatchSize {
+		j := min(i+writeBatchSize, len(keys))
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		results := wb.write(ctx, keys[i:j], writes[i:j])
+		for k, err := range results {
+			wb.finish(keys[i+k], writes[i+k], err)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 写入一批key,返回的错误与keys一一对应
+func (wb *writeBuffer) write(ctx context.Context, keys []string, writes []*pendingWrite) []error {
+	errs := make([]error, len(keys))
+	var setKeys []string
+	var setValues [][]byte
+	var setIndex []int
+	for i, w := range writes {
+		if w.delete {
+			errs[i] = wb.g.deleter.Delete(ctx, keys[i])
+		} else {
+			setKeys, setValues, setIndex = append(setKeys, keys[i]), append(setValues, w.value), append(setIndex, i)
+		}
+	}
+	if bs, ok := wb.g.setter.(BatchSetter); ok && len(setKeys) > 0 {
+		results := bs.SetMany(ctx, setKeys, setValues)
+		for k, i := range setIndex {
+			if k < len(results) {
+				errs[i] = results[k]
+			} else {
+				errs[i] = status.Errorf(codes.Internal, "batch setter returned %d results for %d keys", len(results), len(setKeys))
+			}
+		}
+		return errs
+	}
+	for k, i := range setIndex {
+		errs[i] = wb.g.setter.Set(ctx, setKeys[k], setValues[k])
+	}
+	return errs
+}
+
+// 一次写入结束,成功或超过重试次数时若未被新的写入覆盖则移出队列
+func (wb *writeBuffer) finish(key string, w *pendingWrite, err error) {
+	if err == nil {
+		wb.g.stats.writes.Add(1)
+	} else {
+		wb.g.stats.writeErrors.Add(1)
+		w.attempts++
+	}
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+	if wb.pending[key] != w {
+		return
+	}
+	if err == nil {
+		delete(wb.pending, key)
+	} else if w.attempts > wb.retries {
+		delete(wb.pending, key)
+		wb.g.logger.Error("failed to write to data source, write dropped", "key", key, "attempts", w.attempts, "err", err)
+	}
+}
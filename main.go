@@ -1,16 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/LudensCS/Cache/cache"
+	"github.com/LudensCS/Cache/cache/membership"
+	"github.com/LudensCS/Cache/cache/metrics"
 	"github.com/LudensCS/Cache/database/mysql"
 	"github.com/LudensCS/Cache/middlewares/bloomfilter"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
@@ -19,27 +38,73 @@ import (
 var db *gorm.DB
 var dsn string
 
+// api网关与数据库查询的tracer
+var tracer = otel.Tracer("github.com/LudensCS/Cache")
+
+// api网关的指标
+var (
+	apiRequests = metrics.Default.Counter("api_requests_total",
+		"Total requests served by the API gateway, by HTTP status code.", "code")
+	bloomRejects = metrics.Default.Counter("api_bloom_filter_rejects_total",
+		"Total requests rejected by the bloom filter before reaching the cache.")
+)
+
 // CreateGroup 创立缓存组
 func CreateGroup() *cache.Group {
-	return cache.NewGroup("scores", 2<<10, cache.GetterFunc(
-		func(key string) ([]byte, error) {
-			log.Println("[SlowDB] search key", key)
-			row, err := mysql.Select(db, key)
+	return cache.NewGroup("scores", 2<<10, cache.ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			slog.Debug("search key in database", "key", key)
+			ctx, span := tracer.Start(ctx, "mysql.Select", trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("key", key)))
+			defer span.End()
+			row, err := mysql.Select(db.WithContext(ctx), key)
 			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(otelcodes.Error, err.Error())
 				return nil, err
 			}
+			if len(row) == 0 {
+				return nil, cache.ErrNotFound
+			}
 			return row[0].Value, nil
 		},
-	))
+	), cache.WithNegativeCache(10*time.Second, 1<<10))
 }
 
-// StartCacheServer 启动缓存服务
-func StartCacheServer(addr string, addrs []string, g *cache.Group) {
+// StartCacheServer 在后台启动缓存服务,通过种子节点seed加入集群,seed为空时作为集群的第一个节点
+// 退出前需调用返回的服务的Stop,离开集群并关闭连接
+func StartCacheServer(addr string, seed string, g *cache.Group) *cache.CacheServer {
 	peers := cache.NewCacheServer(addr)
-	peers.Set(addrs...)
+	members := membership.New(addr)
+	peers.UseMembership(members)
 	g.RegisterPeers(peers)
-	log.Println("Cache is Running at", addr)
-	log.Fatal(peers.Run())
+	if seed != "" {
+		go JoinCluster(members, seed)
+	}
+	go func() {
+		if err := peers.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	slog.Info("cache server is running", "addr", addr)
+	return peers
+}
+
+// JoinCluster 通过种子节点加入集群,种子节点尚未启动或暂时不可达时按指数退避重试直到成功
+func JoinCluster(members *membership.Memberlist, seed string) {
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := members.Join(ctx, seed)
+		cancel()
+		if err == nil {
+			slog.Info("joined cluster", "seed", seed, "attempts", attempt)
+			return
+		}
+		slog.Warn("failed to join cluster, retrying", "seed", seed, "attempt", attempt, "retry_in", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 10*time.Second)
+	}
 }
 
 // StartAPIServer 在本机apiAddr上启动api网关服务
@@ -49,24 +114,67 @@ func StartAPIServer(apiAddr string, g *cache.Group) {
 	http.Handle("/api", http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			key := r.URL.Query().Get("key")
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, "GET /api", trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("key", key)))
+			defer span.End()
 			if !Filter.Query(key) {
+				span.SetAttributes(attribute.String("bloom", "reject"))
+				bloomRejects.Inc()
+				apiRequests.Inc(strconv.Itoa(http.StatusNotFound))
 				err := status.Errorf(codes.NotFound, "%v not exist", key)
 				http.Error(w, err.Error(), http.StatusNotFound)
 				return
 			}
-			view, err := g.Get(key)
+			view, err := g.GetContext(ctx, key)
+			if errors.Is(err, cache.ErrNotFound) {
+				apiRequests.Inc(strconv.Itoa(http.StatusNotFound))
+				http.Error(w, status.Errorf(codes.NotFound, "%v not exist", key).Error(), http.StatusNotFound)
+				return
+			}
 			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(otelcodes.Error, err.Error())
+				apiRequests.Inc(strconv.Itoa(http.StatusInternalServerError))
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			apiRequests.Inc(strconv.Itoa(http.StatusOK))
 			w.Header().Set("Content-Type", "application/octet-stream")
 			w.Write(view.ByteSlice())
 		},
 	))
-	log.Println("fontend server is running at :", apiAddr)
+	//example : http://apiAddr/metrics
+	http.Handle("/metrics", metrics.Handler())
+	slog.Info("api server is running", "addr", apiAddr)
 	log.Fatal(http.ListenAndServe(apiAddr[7:], nil))
 }
 
+// StartMetricsServer 在metricsAddr上提供缓存节点的/metrics接口
+func StartMetricsServer(metricsAddr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	slog.Info("metrics server is running", "addr", metricsAddr)
+	log.Fatal(http.ListenAndServe(metricsAddr[7:], mux))
+}
+
+// InitTracing 将追踪以OTLP/HTTP导出到collector,返回的函数在退出前导出剩余的span并关闭导出器
+func InitTracing(collector string, service string) (shutdown func(context.Context) error, err error) {
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(collector, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx, resource.WithFromEnv(), resource.WithTelemetrySDK(),
+		resource.WithSchemaURL(semconv.SchemaURL), resource.WithAttributes(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
 // LoadDB 将数据库中的数据加载到布隆过滤器
 func LoadDB() *bloomfilter.Bloomfilter {
 	rows, err := mysql.Select(db, "*")
@@ -82,12 +190,20 @@ func LoadDB() *bloomfilter.Bloomfilter {
 
 var (
 	port     int
+	mport    int
+	otlp     string
+	logLevel string
+	seed     string
 	api      bool
 	loaddata bool
 )
 
 func init() {
 	flag.IntVar(&port, "port", 8000, "the port of cache server")
+	flag.IntVar(&mport, "metrics", 0, "the port of /metrics for the cache server, 0 to disable")
+	flag.StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	flag.StringVar(&otlp, "otlp", "", "OTLP/HTTP collector to export traces to, e.g. http://localhost:4318")
+	flag.StringVar(&seed, "seed", "", "address of any node already in the cluster, e.g. http://localhost:8001")
 	flag.BoolVar(&api, "api", false, "start a api server?")
 	flag.BoolVar(&loaddata, "load", false, "initial database with pre-datas")
 	if err := godotenv.Load("./variables.env"); err != nil {
@@ -101,6 +217,11 @@ func init() {
 }
 func main() {
 	flag.Parse()
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 	//数据库初始化
 	var err error
 	db, err = mysql.Register(dsn)
@@ -117,25 +238,45 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		log.Println("local data has loaded")
+		slog.Info("local data has loaded", "rows", len(datas))
 		return
 	}
-	apiAddr := "http://localhost:9999"
-	//分布式系统中各节点地址
-	addrMap := map[int]string{
-		8001: "http://localhost:8001",
-		8002: "http://localhost:8002",
-		8003: "http://localhost:8003",
-	}
-	var addrs []string
-	for _, addr := range addrMap {
-		addrs = append(addrs, addr)
+	//追踪上下文以W3C traceparent在api网关与节点之间传播,未指定collector时只传播不导出
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if otlp != "" {
+		shutdown, err := InitTracing(otlp, fmt.Sprintf("cache-%d", port))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("failed to flush traces", "err", err)
+			}
+		}()
 	}
-	//创建一个缓存组,名字叫"scores",[]addrMap内的三个服务器都属于该同名缓存组集群内
-	//它们逻辑上属于同一个分布式系统
+	apiAddr := "http://localhost:9999"
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	//创建一个缓存组,名字叫"scores",通过同一种子节点加入的服务器都属于该同名缓存组集群内
+	//它们逻辑上属于同一个分布式系统,节点的加入与离开通过gossip协议自动传播
 	Cache := CreateGroup()
 	if api {
 		go StartAPIServer(apiAddr, Cache)
 	}
-	StartCacheServer(addrMap[port], addrs, Cache)
+	if mport != 0 {
+		go StartMetricsServer(fmt.Sprintf("http://localhost:%d", mport))
+	}
+	peers := StartCacheServer(addr, seed, Cache)
+	//收到退出信号后离开集群,使其他节点不再将请求路由到本节点,再停止缓存组的后台协程
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	slog.Info("shutting down")
+	peers.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Cache.Shutdown(ctx); err != nil {
+		slog.Error("failed to shut down cache group", "err", err)
+	}
 }
@@ -5,9 +5,9 @@ go build -o server.exe main.go
 
 ./server -load true
 
-./server -port 8001 &
-./server -port 8002 &
-./server -port 8003 -api true &
+./server -port 8001 -metrics 9001 &
+./server -port 8002 -seed http://localhost:8001 -metrics 9002 &
+./server -port 8003 -seed http://localhost:8001 -metrics 9003 -api true &
 
 sleep 2
 